@@ -1,9 +1,39 @@
 package main
 
-import "TransactiStream/internal/app"
-
-var confDir = ""
+import (
+	"TransactiStream/internal/app"
+	"TransactiStream/internal/config"
+	"flag"
+	"fmt"
+	"os"
+)
 
 func main() {
-	app.Run(confDir)
+	configPath := flag.String("config", envOr("CONFIG_PATH", config.DefaultPath), "path to the config file")
+	flag.Parse()
+
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		app.Run(*configPath)
+	case "import":
+		if err := app.Import(*configPath, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "rebuild-statistics":
+		if err := app.RebuildStatistics(*configPath, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected import or rebuild-statistics\n", cmd)
+		os.Exit(2)
+	}
+}
+
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
 }
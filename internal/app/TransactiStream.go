@@ -4,59 +4,73 @@ import (
 	"TransactiStream/internal/config"
 	httphandler "TransactiStream/internal/delivery/http"
 	kafkaService "TransactiStream/internal/delivery/kafka"
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
+	"TransactiStream/internal/importer"
 	"TransactiStream/internal/logger"
+	"TransactiStream/internal/metrics"
 	"TransactiStream/internal/repository/postgres"
+	"TransactiStream/internal/retry"
+	"TransactiStream/internal/tracing"
+	"TransactiStream/internal/webhook"
 	"context"
+	"errors"
 	"fmt"
-	"github.com/jackc/pgx/v5"
-	"log/slog"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"net"
 	"net/http"
-	"os"
+	"net/url"
 )
 
-func Run(confDir string) {
+func Run(configPath string) {
 	logger.InitLogger()
 
-	cfg, err := config.MustLoad(confDir)
+	cfg, err := config.Load(configPath)
 	if err != nil {
-		logger.Errorf("failed to load config: %v", err)
-		os.Exit(1)
+		logger.Log.Fatal().Err(err).Msg("failed to load config")
 	}
 
+	if err = logger.Setup(cfg.Log.Level, cfg.Log.Format); err != nil {
+		logger.Log.Fatal().Err(err).Msg("failed to set up logger")
+	}
+	logger.SetPIIFields(cfg.Log.PIIFields)
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	connString := fmt.Sprintf("postgres://%s:%s@%s:%s/%s",
-		cfg.Postgres.User,
-		cfg.Postgres.Password,
-		cfg.Postgres.Host,
-		cfg.Postgres.Port,
-		cfg.Postgres.DBName,
-	)
-	logger.Infof("Connect string: %s", connString)
-
-	conn, err := pgx.Connect(ctx, connString)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		ServiceName: cfg.Tracing.ServiceName,
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
 	if err != nil {
-		logger.Errorf("Unable to establish connection: %v", err)
-		os.Exit(1)
+		logger.Log.Fatal().Err(err).Msg("Unable to set up tracing")
 	}
-	defer conn.Close(context.Background())
-	logger.Info("Connection established")
+	defer shutdownTracing(context.Background())
 
-	err = postgres.CreateTables(ctx, *conn)
+	conn, err := connectPostgres(ctx, cfg)
 	if err != nil {
-		logger.Errorf("Unable to create tables: %v", err)
-		os.Exit(1)
+		logger.Log.Fatal().Err(err).Msg("Unable to connect to postgres")
 	}
-	logger.Info("Tables created")
+	defer conn.Close()
 
 	repo := postgres.NewPostgres(conn)
 
-	logger.Infof("Kafka: {Brokers: %v, WriteTopic: %v, ReadTopic %v}",
-		cfg.Kafka.Brokers, cfg.Kafka.WriteTopic, cfg.Kafka.ReadTopic)
+	logger.Log.Info().
+		Strs("brokers", cfg.Kafka.Brokers).
+		Str("write_topic", cfg.Kafka.WriteTopic).
+		Str("read_topic", cfg.Kafka.ReadTopic).
+		Msg("Kafka configured")
+
+	// status changes recorded by any instance reach the local subscribers through the database
+	hub := events.NewHub()
+	listener := postgres.NewListener(conn.Config().ConnConfig.Copy(), repo, retryPolicy(cfg.Startup.Postgres))
+	go listener.Run(ctx, hub.Publish)
 
 	kafkaSrv := kafkaService.NewKafka(
-		[]string{cfg.Kafka.Brokers},
+		cfg.Kafka.Brokers,
 		cfg.Kafka.WriteTopic,
 		cfg.Kafka.ReadTopic,
 		cfg.Kafka.GroupID,
@@ -64,38 +78,144 @@ func Run(confDir string) {
 	)
 
 	for _, topic := range []string{cfg.Kafka.WriteTopic, cfg.Kafka.ReadTopic} {
-		err = kafkaService.CreateTopic([]string{cfg.Kafka.Brokers}, topic)
+		err = retry.Do(ctx, "kafka topic "+topic, retryPolicy(cfg.Startup.Kafka), func(context.Context) error {
+			return kafkaService.CreateTopic(cfg.Kafka.Brokers, topic)
+		})
 		if err != nil {
-			logger.Errorf("Unable to create topic: %v", err)
-			os.Exit(1)
+			logger.Log.Fatal().Err(err).Str("topic", topic).Msg("Unable to create topic")
 		}
 	}
-	logger.Info("Topics created")
+	logger.Log.Info().Msg("Topics created")
+
+	validator, err := newValidator(cfg)
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("Unable to set up validation")
+	}
+
+	handler := httphandler.NewHandler(repo, kafkaSrv, hub, validator, httphandler.Limits{
+		MaxBodyBytes:      cfg.Validation.MaxBodyBytes,
+		MaxBatchItems:     cfg.Batch.MaxItems,
+		MaxBatchBodyBytes: cfg.Batch.MaxBodyBytes,
+		MaxWait:           cfg.HTTP.MaxWait,
+		PendingTimeout:    cfg.Statistics.PendingTimeout,
+	})
+	checks := map[string]httphandler.CheckFunc{
+		"postgres": repo.Ping,
+		"kafka":    kafkaSrv.Ping,
+		"consumer": func(context.Context) error {
+			if !kafkaSrv.Running() {
+				return errors.New("consumer is not running")
+			}
+			return nil
+		},
+	}
+	if cfg.Webhook.MaxBacklog > 0 {
+		checks["webhook_backlog"] = httphandler.BacklogCheck("due webhook deliveries", cfg.Webhook.MaxBacklog, repo.CountDueDeliveries)
+	}
+	health := httphandler.NewHealthHandler(checks)
+
+	imports := httphandler.NewImportHandler(repo,
+		importer.New(repo, kafkaSrv, validator, cfg.Import.ChunkSize),
+		cfg.Import.MaxBodyBytes, cfg.Import.MaxConcurrent)
+	go imports.FailStaleImports(ctx)
 
-	handler := httphandler.NewHandler(repo, kafkaSrv)
+	stream := httphandler.NewStreamHandler(repo, hub, cfg.HTTP.AllowedOrigins)
 
-	http.HandleFunc("/transaction", handler.CreateTransaction)
-	http.HandleFunc("/transactions", handler.GetAllTransactions)
-	http.HandleFunc("/statistics", handler.GetStatistics)
+	webhooks := httphandler.NewWebhookHandler(repo, cfg.Validation.MaxBodyBytes)
+
+	router := httphandler.NewRouter(handler, imports, stream, webhooks, health, metrics.Handler())
+
+	srvHandler := httphandler.Chain(router,
+		httphandler.RequestID,
+		httphandler.AccessLog,
+		httphandler.Recoverer,
+	)
 
 	srv := &http.Server{
-		Addr: cfg.HTTP.Host + ":" + cfg.HTTP.Port,
+		Addr:    cfg.HTTP.Host + ":" + cfg.HTTP.Port,
+		Handler: srvHandler,
 	}
 
 	go func() {
 		if err := kafkaSrv.ReceiveMessages(ctx); err != nil {
-			logger.Errorf("Kafka consumer error: %v", err)
+			logger.Log.Error().Err(err).Msg("Kafka consumer error")
 		}
 	}()
 
+	worker := webhook.NewWorker(repo, webhook.Options{
+		PollInterval:    cfg.Webhook.PollInterval,
+		BatchSize:       cfg.Webhook.BatchSize,
+		Timeout:         cfg.Webhook.Timeout,
+		MaxAttempts:     cfg.Webhook.MaxAttempts,
+		Retry:           retryPolicy(cfg.Webhook.Retry()),
+		AllowedNetworks: cfg.Webhook.Networks(),
+	})
+	go worker.Run(ctx)
+
 	go func() {
-		logger.Info("Server started")
+		logger.Log.Info().Str("addr", srv.Addr).Msg("Server started")
 		if err := srv.ListenAndServe(); err != nil {
-			slog.Info("error: ", err)
-			panic(err)
+			logger.Log.Panic().Err(err).Msg("Server error")
 		}
-		logger.Info("Server stopped ")
+		logger.Log.Info().Msg("Server stopped")
 	}()
 
 	select {}
 }
+
+// connectPostgres opens a pool, waits for the server to come up and creates the tables.
+func connectPostgres(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	// the password may come from a file and contain any character, url.URL escapes it
+	connString := (&url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(cfg.Postgres.User, cfg.Postgres.Password),
+		Host:   net.JoinHostPort(cfg.Postgres.Host, cfg.Postgres.Port),
+		Path:   cfg.Postgres.DBName,
+	}).String()
+	logger.Log.Info().Str("dsn", logger.RedactDSN(connString)).Msg("Connecting to postgres")
+
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse connection string: %w", err)
+	}
+	poolConfig.ConnConfig.Tracer = postgres.QueryTracer{}
+
+	conn, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create connection pool: %w", err)
+	}
+
+	err = retry.Do(ctx, "postgres", retryPolicy(cfg.Startup.Postgres), conn.Ping)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to establish connection: %w", err)
+	}
+	logger.Log.Info().Msg("Connection established")
+
+	err = postgres.CreateTables(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to create tables: %w", err)
+	}
+	logger.Log.Info().Msg("Tables created")
+
+	return conn, nil
+}
+
+func newValidator(cfg *config.Config) (*domain.Validator, error) {
+	return domain.NewValidator(domain.ValidationRules{
+		UserIDPattern: cfg.Validation.UserIDPattern,
+		DefaultLimits: domain.AmountLimits{Min: cfg.Validation.MinAmount, Max: cfg.Validation.MaxAmount},
+		Limits:        cfg.Validation.Currencies,
+	})
+}
+
+func retryPolicy(cfg config.RetryConfig) retry.Policy {
+	return retry.Policy{
+		InitialInterval: cfg.InitialInterval,
+		MaxInterval:     cfg.MaxInterval,
+		Multiplier:      cfg.Multiplier,
+		Jitter:          cfg.Jitter,
+		MaxElapsedTime:  cfg.MaxElapsedTime,
+	}
+}
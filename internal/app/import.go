@@ -0,0 +1,128 @@
+package app
+
+import (
+	"TransactiStream/internal/config"
+	kafkaService "TransactiStream/internal/delivery/kafka"
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/importer"
+	"TransactiStream/internal/logger"
+	"TransactiStream/internal/repository/postgres"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+)
+
+// Import loads the CSV or NDJSON file named in args, as in
+// `import [-format csv|ndjson] [-skip-publish] [-chunk-size N] [-errors report.csv] FILE`,
+// and writes the rejected lines to a CSV report next to it.
+func Import(configPath string, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "file format, csv or ndjson (default: from the file extension)")
+	skipPublish := fs.Bool("skip-publish", false, "store rows without publishing them to Kafka, e.g. already processed history")
+	chunkSize := fs.Int("chunk-size", 0, "rows stored per insert (default: import.chunksize)")
+	reportPath := fs.String("errors", "", "path of the error report (default: FILE.errors.csv)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: import [flags] FILE")
+	}
+	path := fs.Arg(0)
+
+	logger.InitLogger()
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err = logger.Setup(cfg.Log.Level, cfg.Log.Format); err != nil {
+		return fmt.Errorf("failed to set up logger: %w", err)
+	}
+	logger.SetPIIFields(cfg.Log.PIIFields)
+
+	if *format == "" {
+		*format = formatOf(path)
+	}
+	if *chunkSize <= 0 {
+		*chunkSize = cfg.Import.ChunkSize
+	}
+	if *reportPath == "" {
+		*reportPath = path + ".errors.csv"
+	}
+
+	validator, err := newValidator(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to set up validation: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	conn, err := connectPostgres(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	repo := postgres.NewPostgres(conn)
+
+	var publisher importer.Publisher
+	if !*skipPublish {
+		kafkaSrv := kafkaService.NewKafka(cfg.Kafka.Brokers, cfg.Kafka.WriteTopic, cfg.Kafka.ReadTopic, cfg.Kafka.GroupID, repo)
+		defer kafkaSrv.Close()
+		publisher = kafkaSrv
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reportFile, err := os.Create(*reportPath)
+	if err != nil {
+		return err
+	}
+	defer reportFile.Close()
+
+	report, err := importer.NewCSVReport(reportFile)
+	if err != nil {
+		return fmt.Errorf("failed to write error report: %w", err)
+	}
+
+	imp := &domain.Import{Format: *format, SkipPublish: *skipPublish}
+	err = importer.New(repo, publisher, validator, *chunkSize).Run(ctx, file, imp,
+		func(_ context.Context, errs []domain.ImportError) error {
+			return report.Write(errs)
+		}, nil)
+	if flushErr := report.Flush(); flushErr != nil && err == nil {
+		err = fmt.Errorf("failed to write error report: %w", flushErr)
+	}
+
+	fmt.Printf("total: %d, imported: %d, rejected: %d, publish failed: %d\n",
+		imp.Total, imp.Imported, imp.Rejected, imp.PublishFailed)
+	if imp.Rejected+imp.PublishFailed > 0 {
+		fmt.Printf("error report: %s\n", *reportPath)
+	} else {
+		reportFile.Close()
+		os.Remove(*reportPath)
+	}
+
+	if err != nil {
+		return fmt.Errorf("import stopped after %d rows: %w", imp.Total, err)
+	}
+	return nil
+}
+
+func formatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return importer.FormatNDJSON
+	}
+	return importer.FormatCSV
+}
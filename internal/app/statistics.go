@@ -0,0 +1,53 @@
+package app
+
+import (
+	"TransactiStream/internal/config"
+	"TransactiStream/internal/logger"
+	"TransactiStream/internal/repository/postgres"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// RebuildStatistics recomputes the statistics rollups from the stored transactions, as in
+// `rebuild-statistics`. Writes to transactions wait while it runs.
+func RebuildStatistics(configPath string, args []string) error {
+	fs := flag.NewFlagSet("rebuild-statistics", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: rebuild-statistics")
+	}
+
+	logger.InitLogger()
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err = logger.Setup(cfg.Log.Level, cfg.Log.Format); err != nil {
+		return fmt.Errorf("failed to set up logger: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	conn, err := connectPostgres(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	started := time.Now()
+	if err = postgres.NewPostgres(conn).RebuildStatistics(ctx); err != nil {
+		return fmt.Errorf("failed to rebuild statistics: %w", err)
+	}
+
+	fmt.Printf("statistics rebuilt in %s\n", time.Since(started).Round(time.Millisecond))
+	return nil
+}
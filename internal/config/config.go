@@ -1,51 +1,366 @@
 package config
 
 import (
+	"TransactiStream/internal/domain"
+	"errors"
+	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
+	"net"
+	"net/netip"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const DefaultPath = "config.yaml"
+
 type (
 	Config struct {
-		Postgres PostgresConfig
-		HTTP     HTTPConfig
-		Kafka    KafkaConfig
+		Postgres   PostgresConfig   `yaml:"postgres" env-prefix:"POSTGRES_"`
+		HTTP       HTTPConfig       `yaml:"http" env-prefix:"HTTP_"`
+		Kafka      KafkaConfig      `yaml:"kafka" env-prefix:"KAFKA_"`
+		Startup    StartupConfig    `yaml:"startup" env-prefix:"STARTUP_"`
+		Log        LogConfig        `yaml:"log" env-prefix:"LOG_"`
+		Tracing    TracingConfig    `yaml:"tracing" env-prefix:"TRACING_"`
+		Validation ValidationConfig `yaml:"validation" env-prefix:"VALIDATION_"`
+		Batch      BatchConfig      `yaml:"batch" env-prefix:"BATCH_"`
+		Import     ImportConfig     `yaml:"import" env-prefix:"IMPORT_"`
+		Webhook    WebhookConfig    `yaml:"webhook" env-prefix:"WEBHOOK_"`
+		Statistics StatisticsConfig `yaml:"statistics" env-prefix:"STATISTICS_"`
 	}
 
 	PostgresConfig struct {
-		Host     string
-		Port     string
-		User     string
-		Password string
-		DBName   string
+		Host     string `yaml:"host" env:"HOST" env-default:"localhost"`
+		Port     string `yaml:"port" env:"PORT" env-default:"5432"`
+		User     string `yaml:"user" env:"USER" env-default:"postgres"`
+		Password string `yaml:"password" env:"PASSWORD"`
+		// PasswordFile points to a file with the password (e.g. a Docker secret) and takes precedence over Password.
+		PasswordFile string `yaml:"passwordfile" env:"PASSWORD_FILE"`
+		DBName       string `yaml:"dbname" env:"DBNAME" env-default:"transactions"`
 	}
 
 	HTTPConfig struct {
-		Host string
-		Port string
+		Host string `yaml:"host" env:"HOST" env-default:"0.0.0.0"`
+		Port string `yaml:"port" env:"PORT" env-default:"8009"`
+		// AllowedOrigins are the browser origins besides the API's own that may open WebSockets; "*" allows any.
+		AllowedOrigins []string `yaml:"allowedorigins" env:"ALLOWED_ORIGINS" env-separator:","`
+		// MaxWait caps the ?wait duration of requests that wait for a processing result.
+		MaxWait time.Duration `yaml:"maxwait" env:"MAX_WAIT" env-default:"30s"`
 	}
 
 	KafkaConfig struct {
-		Brokers    string
-		WriteTopic string
-		ReadTopic  string
-		GroupID    string
+		Brokers    []string `yaml:"brokers" env:"BROKERS" env-separator:"," env-default:"localhost:9092"`
+		WriteTopic string   `yaml:"writetopic" env:"WRITE_TOPIC" env-default:"new_transactions"`
+		ReadTopic  string   `yaml:"readtopic" env:"READ_TOPIC" env-default:"processed_transactions"`
+		GroupID    string   `yaml:"groupid" env:"GROUP_ID" env-default:"transactions"`
+	}
+
+	LogConfig struct {
+		Level  string `yaml:"level" env:"LEVEL" env-default:"info"`
+		Format string `yaml:"format" env:"FORMAT" env-default:"console"`
+		// PIIFields are transaction fields masked in logs.
+		PIIFields []string `yaml:"piifields" env:"PII_FIELDS" env-separator:"," env-default:"user_id,amount"`
+	}
+
+	TracingConfig struct {
+		ServiceName string `yaml:"servicename" env:"SERVICE_NAME" env-default:"transactistream"`
+		// Exporter is one of none, stdout or otlp.
+		Exporter string `yaml:"exporter" env:"EXPORTER" env-default:"none"`
+		// Endpoint is the OTLP/HTTP collector address used by the otlp exporter.
+		Endpoint    string  `yaml:"endpoint" env:"ENDPOINT" env-default:"localhost:4318"`
+		Insecure    bool    `yaml:"insecure" env:"INSECURE" env-default:"true"`
+		SampleRatio float64 `yaml:"sampleratio" env:"SAMPLE_RATIO" env-default:"1"`
+	}
+
+	ValidationConfig struct {
+		MaxBodyBytes  int64   `yaml:"maxbodybytes" env:"MAX_BODY_BYTES" env-default:"65536"`
+		UserIDPattern string  `yaml:"useridpattern" env:"USER_ID_PATTERN" env-default:"[A-Za-z0-9_.@-]{1,64}"`
+		MinAmount     float64 `yaml:"minamount" env:"MIN_AMOUNT" env-default:"0.00000001"`
+		MaxAmount     float64 `yaml:"maxamount" env:"MAX_AMOUNT" env-default:"1000000000"`
+		// Currencies override MinAmount and MaxAmount per currency code.
+		Currencies map[string]domain.AmountLimits `yaml:"currencies"`
+	}
+
+	// BatchConfig bounds POST /transactions/batch.
+	BatchConfig struct {
+		MaxItems     int   `yaml:"maxitems" env:"MAX_ITEMS" env-default:"1000"`
+		MaxBodyBytes int64 `yaml:"maxbodybytes" env:"MAX_BODY_BYTES" env-default:"8388608"`
+	}
+
+	// ImportConfig bounds file imports through the API and the import command.
+	ImportConfig struct {
+		ChunkSize    int   `yaml:"chunksize" env:"CHUNK_SIZE" env-default:"1000"`
+		MaxBodyBytes int64 `yaml:"maxbodybytes" env:"MAX_BODY_BYTES" env-default:"1073741824"`
+		// MaxConcurrent is how many imports through the API may run at once on an instance.
+		MaxConcurrent int `yaml:"maxconcurrent" env:"MAX_CONCURRENT" env-default:"4"`
+	}
+
+	// WebhookConfig controls delivery of outgoing webhooks. A failing delivery is retried with an
+	// exponential backoff until MaxAttempts or MaxElapsedTime since the event is reached.
+	WebhookConfig struct {
+		PollInterval    time.Duration `yaml:"pollinterval" env:"POLL_INTERVAL" env-default:"1s"`
+		BatchSize       int           `yaml:"batchsize" env:"BATCH_SIZE" env-default:"20"`
+		Timeout         time.Duration `yaml:"timeout" env:"TIMEOUT" env-default:"10s"`
+		MaxAttempts     int           `yaml:"maxattempts" env:"MAX_ATTEMPTS" env-default:"10"`
+		InitialInterval time.Duration `yaml:"initialinterval" env:"INITIAL_INTERVAL" env-default:"10s"`
+		MaxInterval     time.Duration `yaml:"maxinterval" env:"MAX_INTERVAL" env-default:"1h"`
+		Multiplier      float64       `yaml:"multiplier" env:"MULTIPLIER" env-default:"2"`
+		Jitter          float64       `yaml:"jitter" env:"JITTER" env-default:"0.2"`
+		MaxElapsedTime  time.Duration `yaml:"maxelapsedtime" env:"MAX_ELAPSED_TIME" env-default:"24h"`
+		// AllowedNetworks are CIDR prefixes webhooks may be delivered to although they are not
+		// publicly routable; any other loopback, private or link-local address is refused.
+		AllowedNetworks []string `yaml:"allowednetworks" env:"ALLOWED_NETWORKS" env-separator:","`
+		// MaxBacklog is how many deliveries may be due at once before /readyz reports the service
+		// unavailable; 0 disables the check.
+		MaxBacklog int `yaml:"maxbacklog" env:"MAX_BACKLOG" env-default:"10000"`
+	}
+
+	// StatisticsConfig controls how GET /statistics classifies transactions.
+	StatisticsConfig struct {
+		// PendingTimeout is how long a published transaction may wait for its processing result
+		// before it is counted as timed out; 0 never counts any.
+		PendingTimeout time.Duration `yaml:"pendingtimeout" env:"PENDING_TIMEOUT" env-default:"5m"`
+	}
+
+	// StartupConfig holds retry policies used while waiting for dependencies on startup.
+	StartupConfig struct {
+		Postgres RetryConfig `yaml:"postgres" env-prefix:"POSTGRES_"`
+		Kafka    RetryConfig `yaml:"kafka" env-prefix:"KAFKA_"`
+	}
+
+	RetryConfig struct {
+		InitialInterval time.Duration `yaml:"initialinterval" env:"INITIAL_INTERVAL" env-default:"500ms"`
+		MaxInterval     time.Duration `yaml:"maxinterval" env:"MAX_INTERVAL" env-default:"10s"`
+		Multiplier      float64       `yaml:"multiplier" env:"MULTIPLIER" env-default:"2"`
+		Jitter          float64       `yaml:"jitter" env:"JITTER" env-default:"0.2"`
+		MaxElapsedTime  time.Duration `yaml:"maxelapsedtime" env:"MAX_ELAPSED_TIME" env-default:"2m"`
 	}
 )
 
-func MustLoad(folder string) (*Config, error) {
+// Load reads the config file at path, applies environment overrides and defaults,
+// resolves secret files and validates the result.
+// A missing file is only an error when path is not DefaultPath, so the app can run on env vars alone.
+func Load(path string) (*Config, error) {
 	var cfg Config
 
-	configPath := folder + "config.yaml"
+	if path == "" {
+		path = DefaultPath
+	}
 
-	//check if file exists
-	if _, err := os.Stat(configPath); err != nil {
+	_, err := os.Stat(path)
+	switch {
+	case err == nil:
+		if err = cleanenv.ReadConfig(path, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && path == DefaultPath:
+		if err = cleanenv.ReadEnv(&cfg); err != nil {
+			return nil, fmt.Errorf("failed to read config from env: %w", err)
+		}
+	default:
 		return nil, err
 	}
 
-	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		return nil, err
+	if cfg.Postgres.PasswordFile != "" {
+		if cfg.Postgres.Password, err = readSecret(cfg.Postgres.PasswordFile); err != nil {
+			return nil, fmt.Errorf("failed to read postgres password: %w", err)
+		}
+	}
+
+	if err = cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
 	return &cfg, nil
 }
+
+// Validate reports every problem found in the config at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	required := func(name, value string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+
+	required("postgres.host", c.Postgres.Host)
+	required("postgres.user", c.Postgres.User)
+	required("postgres.dbname", c.Postgres.DBName)
+	errs = append(errs, validatePort("postgres.port", c.Postgres.Port))
+
+	errs = append(errs, validatePort("http.port", c.HTTP.Port))
+	if c.HTTP.MaxWait <= 0 {
+		errs = append(errs, errors.New("http.maxwait must be positive"))
+	}
+
+	if len(c.Kafka.Brokers) == 0 {
+		errs = append(errs, errors.New("kafka.brokers must contain at least one broker"))
+	}
+	for i, broker := range c.Kafka.Brokers {
+		host, port, err := net.SplitHostPort(broker)
+		if err != nil || host == "" {
+			errs = append(errs, fmt.Errorf("kafka.brokers[%d]: %q must be host:port", i, broker))
+			continue
+		}
+		errs = append(errs, validatePort(fmt.Sprintf("kafka.brokers[%d]", i), port))
+	}
+	required("kafka.writetopic", c.Kafka.WriteTopic)
+	required("kafka.readtopic", c.Kafka.ReadTopic)
+	required("kafka.groupid", c.Kafka.GroupID)
+	if c.Kafka.WriteTopic != "" && c.Kafka.WriteTopic == c.Kafka.ReadTopic {
+		errs = append(errs, errors.New("kafka.writetopic and kafka.readtopic must differ"))
+	}
+
+	switch c.Log.Format {
+	case "console", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log.format: %q must be console or json", c.Log.Format))
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "trace", "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level: %q must be one of trace, debug, info, warn, error", c.Log.Level))
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		required("tracing.endpoint", c.Tracing.Endpoint)
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: %q must be none, stdout or otlp", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sampleratio must be between 0 and 1"))
+	}
+
+	if c.Validation.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("validation.maxbodybytes must be positive"))
+	}
+	if _, err := regexp.Compile(c.Validation.UserIDPattern); err != nil {
+		errs = append(errs, fmt.Errorf("validation.useridpattern: %w", err))
+	}
+	errs = append(errs, validateLimits("validation", domain.AmountLimits{Min: c.Validation.MinAmount, Max: c.Validation.MaxAmount}))
+	for currency, limits := range c.Validation.Currencies {
+		errs = append(errs, validateLimits("validation.currencies."+currency, limits))
+	}
+
+	if c.Batch.MaxItems <= 0 {
+		errs = append(errs, errors.New("batch.maxitems must be positive"))
+	}
+	if c.Batch.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("batch.maxbodybytes must be positive"))
+	}
+
+	if c.Import.ChunkSize <= 0 {
+		errs = append(errs, errors.New("import.chunksize must be positive"))
+	}
+	if c.Import.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("import.maxbodybytes must be positive"))
+	}
+	if c.Import.MaxConcurrent <= 0 {
+		errs = append(errs, errors.New("import.maxconcurrent must be positive"))
+	}
+
+	if c.Webhook.PollInterval <= 0 {
+		errs = append(errs, errors.New("webhook.pollinterval must be positive"))
+	}
+	if c.Webhook.BatchSize <= 0 {
+		errs = append(errs, errors.New("webhook.batchsize must be positive"))
+	}
+	if c.Webhook.Timeout <= 0 {
+		errs = append(errs, errors.New("webhook.timeout must be positive"))
+	}
+	if c.Webhook.MaxAttempts <= 0 {
+		errs = append(errs, errors.New("webhook.maxattempts must be positive"))
+	}
+	if c.Webhook.MaxBacklog < 0 {
+		errs = append(errs, errors.New("webhook.maxbacklog must not be negative"))
+	}
+	errs = append(errs, c.Webhook.Retry().validate("webhook"))
+	for i, network := range c.Webhook.AllowedNetworks {
+		if _, err := netip.ParsePrefix(network); err != nil {
+			errs = append(errs, fmt.Errorf("webhook.allowednetworks[%d]: %q must be a CIDR prefix", i, network))
+		}
+	}
+
+	if c.Statistics.PendingTimeout < 0 {
+		errs = append(errs, errors.New("statistics.pendingtimeout must not be negative"))
+	}
+
+	errs = append(errs, c.Startup.Postgres.validate("startup.postgres"))
+	errs = append(errs, c.Startup.Kafka.validate("startup.kafka"))
+
+	return errors.Join(errs...)
+}
+
+// Retry returns the backoff between delivery attempts.
+func (w WebhookConfig) Retry() RetryConfig {
+	return RetryConfig{
+		InitialInterval: w.InitialInterval,
+		MaxInterval:     w.MaxInterval,
+		Multiplier:      w.Multiplier,
+		Jitter:          w.Jitter,
+		MaxElapsedTime:  w.MaxElapsedTime,
+	}
+}
+
+// Networks returns AllowedNetworks parsed; they are expected to be validated.
+func (w WebhookConfig) Networks() []netip.Prefix {
+	networks := make([]netip.Prefix, 0, len(w.AllowedNetworks))
+	for _, network := range w.AllowedNetworks {
+		if prefix, err := netip.ParsePrefix(network); err == nil {
+			networks = append(networks, prefix)
+		}
+	}
+	return networks
+}
+
+func (r RetryConfig) validate(name string) error {
+	var errs []error
+
+	if r.InitialInterval <= 0 {
+		errs = append(errs, fmt.Errorf("%s.initialinterval must be positive", name))
+	}
+	if r.MaxInterval < r.InitialInterval {
+		errs = append(errs, fmt.Errorf("%s.maxinterval must not be less than initialinterval", name))
+	}
+	if r.Multiplier < 1 {
+		errs = append(errs, fmt.Errorf("%s.multiplier must be at least 1", name))
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		errs = append(errs, fmt.Errorf("%s.jitter must be between 0 and 1", name))
+	}
+	if r.MaxElapsedTime < 0 {
+		errs = append(errs, fmt.Errorf("%s.maxelapsedtime must not be negative", name))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateLimits(name string, l domain.AmountLimits) error {
+	if l.Min < 0 || l.Max <= 0 || l.Min > l.Max {
+		return fmt.Errorf("%s: amount limits [%g, %g] are invalid", name, l.Min, l.Max)
+	}
+	return nil
+}
+
+func validatePort(name, port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s: %q is not a valid port", name, port)
+	}
+
+	return nil
+}
+
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+postgres:
+  host: db
+  password: from-file
+kafka:
+  brokers:
+    - kafka:9092
+  writetopic: in
+  readtopic: out
+`)
+	t.Setenv("POSTGRES_HOST", "db.internal")
+	t.Setenv("KAFKA_BROKERS", "k1:9092,k2:9093")
+	t.Setenv("STARTUP_KAFKA_MAX_ELAPSED_TIME", "30s")
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "db.internal", cfg.Postgres.Host)
+	assert.Equal(t, "from-file", cfg.Postgres.Password)
+	assert.Equal(t, "5432", cfg.Postgres.Port)
+	assert.Equal(t, []string{"k1:9092", "k2:9093"}, cfg.Kafka.Brokers)
+	assert.Equal(t, "in", cfg.Kafka.WriteTopic)
+	assert.Equal(t, 30*time.Second, cfg.Startup.Kafka.MaxElapsedTime)
+}
+
+func TestLoad_PasswordFile(t *testing.T) {
+	secret := writeFile(t, "password", "s3cret\n")
+	path := writeFile(t, "config.yaml", "postgres:\n  password: ignored\n")
+	t.Setenv("POSTGRES_PASSWORD_FILE", secret)
+
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.Postgres.Password)
+}
+
+func TestLoad_MissingExplicitPath(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestValidate_AggregatesErrors(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+postgres:
+  port: "99999"
+kafka:
+  brokers:
+    - kafka
+  writetopic: same
+  readtopic: same
+`)
+
+	_, err := Load(path)
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, `postgres.port: "99999" is not a valid port`)
+	assert.ErrorContains(t, err, `kafka.brokers[0]: "kafka" must be host:port`)
+	assert.ErrorContains(t, err, "kafka.writetopic and kafka.readtopic must differ")
+}
+
+func TestValidate_IPv6Broker(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+kafka:
+  brokers:
+    - "[::1]:9092"
+    - "::1:9092"
+  writetopic: in
+  readtopic: out
+`)
+
+	_, err := Load(path)
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "kafka.brokers[0]")
+	assert.ErrorContains(t, err, `kafka.brokers[1]: "::1:9092" must be host:port`)
+}
+
+func TestValidate_WebhookAllowedNetworks(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+kafka:
+  writetopic: in
+  readtopic: out
+webhook:
+  allowednetworks:
+    - 10.0.0.0/8
+    - 10.0.0.1
+`)
+
+	_, err := Load(path)
+	assert.ErrorContains(t, err, `webhook.allowednetworks[1]: "10.0.0.1" must be a CIDR prefix`)
+	assert.NotContains(t, err.Error(), "allowednetworks[0]")
+
+	t.Setenv("WEBHOOK_ALLOWED_NETWORKS", "10.0.0.0/8,fd00::/8")
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}, cfg.Webhook.Networks())
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Per-item outcomes of a batch submission.
+const (
+	BatchItemCreated       = "created"
+	BatchItemRejected      = "rejected"
+	BatchItemPublishFailed = "publish_failed"
+)
+
+// batchRequest is the body of POST /transactions/batch.
+// Items are decoded one by one so that a bad item rejects only itself.
+type batchRequest struct {
+	Transactions []json.RawMessage `json:"transactions"`
+}
+
+type BatchResponse struct {
+	Created       int               `json:"created"`
+	Rejected      int               `json:"rejected"`
+	PublishFailed int               `json:"publish_failed"`
+	Results       []BatchItemResult `json:"results"`
+}
+
+type BatchItemResult struct {
+	Index  int                 `json:"index"`
+	Status string              `json:"status"`
+	ID     string              `json:"id,omitempty"`
+	Errors []domain.FieldError `json:"errors,omitempty"`
+}
+
+// CreateTransactions stores the valid items of a batch with a single insert and publishes
+// them with a single write. Invalid items are rejected individually; items that were stored
+// but not published are reported as publish_failed and marked as unpublished.
+func (h *Handler) CreateTransactions(w http.ResponseWriter, r *http.Request) {
+	var (
+		req = &batchRequest{}
+		err error
+		ctx = r.Context()
+	)
+
+	if err = decodeJSON(w, r, h.limits.MaxBatchBodyBytes, req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	switch n := len(req.Transactions); {
+	case n == 0:
+		writeError(w, r, batchSizeError("must contain at least one transaction"))
+		return
+	case n > h.limits.MaxBatchItems:
+		writeError(w, r, batchSizeError(fmt.Sprintf("must contain at most %d transactions", h.limits.MaxBatchItems)))
+		return
+	}
+
+	resp := &BatchResponse{Results: make([]BatchItemResult, len(req.Transactions))}
+	accepted := make([]*domain.Transaction, 0, len(req.Transactions))
+	acceptedIndex := make([]int, 0, len(req.Transactions))
+
+	for i, raw := range req.Transactions {
+		resp.Results[i].Index = i
+
+		item := &createTransactionRequest{}
+		validation := &domain.ValidationError{}
+		if err = unmarshalStrict(raw, item); err != nil {
+			resp.reject(i, err)
+			continue
+		}
+		trans := item.toTransaction(h.validator, validation)
+		if err = validation.Err(); err != nil {
+			resp.reject(i, err)
+			continue
+		}
+
+		accepted = append(accepted, trans)
+		acceptedIndex = append(acceptedIndex, i)
+	}
+
+	if len(accepted) == 0 {
+		writeJSON(w, r, http.StatusUnprocessableEntity, resp)
+		return
+	}
+
+	if err = h.repo.CreateBatch(ctx, accepted); err != nil {
+		writeError(w, r, fmt.Errorf("failed to create transactions: %w", err))
+		return
+	}
+
+	var unpublished []string
+	publishErrs := h.kafkaSrv.SendMessages(ctx, accepted)
+	for j, trans := range accepted {
+		res := &resp.Results[acceptedIndex[j]]
+		res.ID = trans.ID
+
+		if publishErrs != nil && publishErrs[j] != nil {
+			res.Status = BatchItemPublishFailed
+			res.Errors = []domain.FieldError{{Message: "transaction was stored but could not be published"}}
+			resp.PublishFailed++
+			unpublished = append(unpublished, trans.ID)
+			continue
+		}
+		res.Status = BatchItemCreated
+		resp.Created++
+	}
+	if len(unpublished) > 0 {
+		h.markUnpublished(ctx, unpublished)
+	}
+
+	status := http.StatusCreated
+	if resp.Rejected > 0 || resp.PublishFailed > 0 {
+		status = http.StatusMultiStatus
+	}
+	writeJSON(w, r, status, resp)
+}
+
+// reject records why item i was not accepted.
+func (resp *BatchResponse) reject(i int, err error) {
+	res := &resp.Results[i]
+	res.Status = BatchItemRejected
+	resp.Rejected++
+
+	var validation *domain.ValidationError
+	if errors.As(err, &validation) {
+		res.Errors = validation.Fields
+		return
+	}
+	res.Errors = []domain.FieldError{{Message: toAPIError(err).Message}}
+}
+
+func batchSizeError(message string) error {
+	validation := &domain.ValidationError{}
+	validation.Add("transactions", message)
+	return validation
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postBatch(t *testing.T, router http.Handler, body string) (*httptest.ResponseRecorder, BatchResponse) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/transactions/batch", strings.NewReader(body)))
+
+	var resp BatchResponse
+	if rec.Code != http.StatusBadRequest && rec.Code < http.StatusInternalServerError {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	}
+	return rec, resp
+}
+
+func TestCreateTransactions_AllCreated(t *testing.T) {
+	publisher := &fakePublisher{}
+	rec, resp := postBatch(t, newTestRouterWith(publisher), `{"transactions": [
+		{"user_id": "u1", "amount": 10, "currency": "USD"},
+		{"user_id": "u2", "amount": 20, "currency": "EUR"}
+	]}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 2, resp.Created)
+	assert.Equal(t, []BatchItemResult{
+		{Index: 0, Status: BatchItemCreated, ID: "id-1"},
+		{Index: 1, Status: BatchItemCreated, ID: "id-2"},
+	}, resp.Results)
+	assert.Len(t, publisher.sent, 2)
+}
+
+func TestCreateTransactions_PartialFailure(t *testing.T) {
+	rec, resp := postBatch(t, newTestRouter(), `{"transactions": [
+		{"user_id": "u1", "amount": 10, "currency": "USD"},
+		{"user_id": "u2", "amount": "ten", "currency": "USD"},
+		{"user_id": "u3", "amount": 30, "currency": "USD", "done": true}
+	]}`)
+
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	assert.Equal(t, 1, resp.Created)
+	assert.Equal(t, 2, resp.Rejected)
+
+	assert.Equal(t, BatchItemCreated, resp.Results[0].Status)
+	assert.Equal(t, "id-1", resp.Results[0].ID)
+
+	assert.Equal(t, BatchItemRejected, resp.Results[1].Status)
+	assert.Empty(t, resp.Results[1].ID)
+	assert.Equal(t, []domain.FieldError{{Field: "amount", Message: "must be a number"}}, resp.Results[1].Errors)
+
+	assert.Equal(t, BatchItemRejected, resp.Results[2].Status)
+	assert.Equal(t, []domain.FieldError{{Field: "done", Message: "is read-only"}}, resp.Results[2].Errors)
+}
+
+func TestCreateTransactions_PublishFailed(t *testing.T) {
+	publisher := &fakePublisher{fail: map[string]bool{"id-2": true}}
+	repo := &fakeRepo{stats: &domain.Statistics{}, transactions: map[string]*domain.Transaction{}}
+	rec, resp := postBatch(t, newTestRouterFrom(testDeps{repo: repo, publisher: publisher}), `{"transactions": [
+		{"user_id": "u1", "amount": 10, "currency": "USD"},
+		{"user_id": "u2", "amount": 20, "currency": "USD"}
+	]}`)
+
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	assert.Equal(t, BatchItemPublishFailed, resp.Results[1].Status)
+	assert.Equal(t, "id-2", resp.Results[1].ID)
+	assert.Len(t, publisher.sent, 1)
+	assert.Equal(t, []string{"id-2"}, repo.unpublished)
+}
+
+func TestCreateTransactions_NothingAccepted(t *testing.T) {
+	rec, resp := postBatch(t, newTestRouter(), `{"transactions": [{"user_id": "u1", "amount": -1, "currency": "USD"}]}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, 1, resp.Rejected)
+}
+
+func TestCreateTransactions_BatchSize(t *testing.T) {
+	item := `{"user_id": "u1", "amount": 10, "currency": "USD"}`
+
+	for name, body := range map[string]string{
+		"empty":    `{"transactions": []}`,
+		"too many": `{"transactions": [` + strings.Repeat(item+",", 3) + item + `]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/transactions/batch", strings.NewReader(body)))
+
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+			var errBody ErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errBody))
+			assert.Equal(t, CodeValidation, errBody.Error.Code)
+		})
+	}
+}
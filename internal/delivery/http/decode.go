@@ -0,0 +1,79 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// decodeJSON strictly decodes a single JSON value of at most maxBytes from the request body.
+func decodeJSON(w http.ResponseWriter, r *http.Request, maxBytes int64, v any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+
+	if err := dec.Decode(&json.RawMessage{}); !errors.Is(err, io.EOF) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return decodeError(err)
+		}
+		return badRequest("request body must contain a single JSON value", err)
+	}
+
+	return nil
+}
+
+// unmarshalStrict decodes data like decodeJSON decodes a request body.
+func unmarshalStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	if field, ok := domain.JSONFieldError(err); ok {
+		validation := &domain.ValidationError{}
+		validation.Add(field.Field, field.Message)
+		return validation
+	}
+
+	var (
+		maxErr    *http.MaxBytesError
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+
+	switch {
+	case errors.As(err, &maxErr):
+		return &APIError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    CodePayloadTooLarge,
+			Message: fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit),
+			Err:     err,
+		}
+	case errors.As(err, &typeErr):
+		// request bodies and batch items are all objects
+		validation := &domain.ValidationError{}
+		validation.Add("", "must be an object")
+		return validation
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest("request body is not valid JSON", err)
+	case errors.Is(err, io.EOF):
+		return badRequest("request body is empty", err)
+	}
+
+	return badRequest("request body could not be decoded", err)
+}
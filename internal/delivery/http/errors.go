@@ -0,0 +1,111 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/logger"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Error codes returned to clients in ErrorBody.Code.
+const (
+	CodeBadRequest       = "bad_request"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeValidation       = "validation_failed"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeUnavailable      = "unavailable"
+	CodeInternal         = "internal"
+)
+
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// APIError is an error with a client-facing status, code and message.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Details any
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// badRequest reports malformed input; err is logged but not shown to the client.
+func badRequest(message string, err error) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: message, Err: err}
+}
+
+// toAPIError maps domain errors to statuses and hides everything else behind a generic 500.
+func toAPIError(err error) *APIError {
+	var (
+		apiErr   *APIError
+		validErr *domain.ValidationError
+	)
+
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &validErr):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeValidation, Message: "request validation failed", Details: validErr.Fields, Err: err}
+	case errors.Is(err, domain.ErrValidation):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeValidation, Message: "request validation failed", Err: err}
+	case errors.Is(err, domain.ErrNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "resource not found", Err: err}
+	case errors.Is(err, domain.ErrConflict):
+		return &APIError{Status: http.StatusConflict, Code: CodeConflict, Message: "resource already exists", Err: err}
+	case errors.Is(err, domain.ErrUnavailable):
+		return &APIError{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: "service temporarily unavailable", Err: err}
+	}
+
+	return &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "internal server error", Err: err}
+}
+
+// writeError logs err and renders it as an ErrorResponse.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	apiErr := toAPIError(err)
+
+	event := logger.FromContext(ctx).Warn()
+	if apiErr.Status >= http.StatusInternalServerError {
+		event = logger.FromContext(ctx).Error()
+	}
+	event.Err(err).Int("status", apiErr.Status).Str("code", apiErr.Code).Msg(apiErr.Message)
+
+	writeJSON(w, r, apiErr.Status, ErrorResponse{Error: ErrorBody{
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		Details:   apiErr.Details,
+		RequestID: logger.RequestID(ctx),
+	}})
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.FromContext(r.Context()).Error().Err(err).Msg("Error encoding response")
+	}
+}
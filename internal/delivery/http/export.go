@@ -0,0 +1,95 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/export"
+	"TransactiStream/internal/logger"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// exportFlushRows is how often the export is pushed to the client.
+const exportFlushRows = 1000
+
+// ExportTransactions streams the transactions matching the listing filters as CSV, NDJSON or Parquet.
+// The response is sent in chunks while rows are read from the database, so its size is not known upfront.
+func (h *Handler) ExportTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, err := parseFilter(r)
+	validation := &domain.ValidationError{}
+	if !errors.As(err, &validation) {
+		validation = &domain.ValidationError{}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if export.ContentType(format) == "" {
+		validation.Add("format", fmt.Sprintf("must be %s, %s or %s", export.FormatCSV, export.FormatNDJSON, export.FormatParquet))
+	}
+	if err = validation.Err(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	out := &countingWriter{w: w}
+	enc, err := export.NewEncoder(format, out)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="transactions.`+format+`"`)
+
+	rc := http.NewResponseController(w)
+	rows := 0
+	err = h.repo.Export(ctx, filter, func(trans *domain.Transaction) error {
+		if err := enc.Encode(trans); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows != 0 {
+			return nil
+		}
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	})
+	if err == nil {
+		err = enc.Close()
+	}
+
+	if err != nil {
+		if out.n == 0 {
+			w.Header().Del("Content-Disposition")
+			writeError(w, r, fmt.Errorf("failed to export transactions: %w", err))
+			return
+		}
+		// part of the file is out; abort the connection so the client can't take it for a complete one
+		logger.FromContext(ctx).Error().Err(err).Int("rows", rows).Msg("Export failed")
+		panic(http.ErrAbortHandler)
+	}
+
+	logger.FromContext(ctx).Info().Str("format", format).Int("rows", rows).Msg("Export finished")
+}
+
+// countingWriter tells whether anything has been written to the response yet.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportTransactions_CSV(t *testing.T) {
+	router := newTestRouter()
+	rec, _ := postBatch(t, router, `{"transactions": [
+		{"user_id": "u1", "amount": 10, "currency": "USD", "timestamp": "2024-07-31T20:04:33Z"},
+		{"user_id": "u2", "amount": 20, "currency": "EUR", "timestamp": "2024-07-31T20:05:00Z"}
+	]}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/transactions/export?currency=EUR", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="transactions.csv"`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "id,user_id,amount,currency,done,status,timestamp,processed_at\n"+
+		"id-2,u2,20,EUR,false,pending,2024-07-31T20:05:00Z,\n", rec.Body.String())
+}
+
+func TestExportTransactions_InvalidQuery(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/v1/transactions/export?format=xml&status=done&from=2024-08-01T00:00:00Z&to=2024-07-01T00:00:00Z", nil))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	var body struct {
+		Error struct {
+			Details []domain.FieldError `json:"details"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, []domain.FieldError{
+		{Field: "status", Message: "must be pending, succeeded or failed"},
+		{Field: "to", Message: "must be after from"},
+		{Field: "format", Message: "must be csv, ndjson or parquet"},
+	}, body.Error.Details)
+}
+
+func TestExportTransactions_FailureBeforeOutput(t *testing.T) {
+	validator, _ := domain.NewValidator(domain.ValidationRules{UserIDPattern: `.+`})
+	h := NewHandler(&fakeRepo{exportErr: domain.ErrUnavailable}, &fakePublisher{}, events.NewHub(), validator, Limits{})
+
+	rec := httptest.NewRecorder()
+	h.ExportTransactions(rec, httptest.NewRequest(http.MethodGet, "/v1/transactions/export?format=ndjson", strings.NewReader("")))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("Content-Disposition"))
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// parseFilter reads the transaction filters shared by listing and export from the query string.
+func parseFilter(r *http.Request) (domain.TransactionFilter, error) {
+	var (
+		query      = r.URL.Query()
+		validation = &domain.ValidationError{}
+		filter     = domain.TransactionFilter{
+			UserID:   query.Get("user_id"),
+			Currency: strings.ToUpper(query.Get("currency")),
+			Status:   domain.Status(query.Get("status")),
+		}
+	)
+
+	switch filter.Status {
+	case "", domain.StatusPending, domain.StatusSucceeded, domain.StatusFailed:
+	default:
+		validation.Add("status", "must be pending, succeeded or failed")
+	}
+
+	filter.From, filter.To = parseWindow(query, validation)
+
+	return filter, validation.Err()
+}
+
+// maxStatisticsBuckets bounds the length of a statistics series.
+const maxStatisticsBuckets = 10000
+
+// parseStatisticsFilter reads the currency, window and bucket interval of GET /statistics.
+// A series starts at from, which is required with interval, and ends at to or now.
+// Transactions pending for longer than pendingTimeout are counted as timed out.
+func parseStatisticsFilter(r *http.Request, pendingTimeout time.Duration) (domain.StatisticsFilter, error) {
+	var (
+		query      = r.URL.Query()
+		validation = &domain.ValidationError{}
+		filter     = domain.StatisticsFilter{
+			Currency: strings.ToUpper(query.Get("currency")),
+			Interval: domain.Interval(query.Get("interval")),
+		}
+	)
+
+	filter.From, filter.To = parseWindow(query, validation)
+	if pendingTimeout > 0 {
+		filter.TimedOutBefore = time.Now().UTC().Add(-pendingTimeout)
+	}
+
+	if filter.Interval != "" {
+		step := filter.Interval.Duration()
+		switch {
+		case step == 0:
+			validation.Add("interval", "must be minute, hour or day")
+		case filter.From.IsZero():
+			validation.Add("from", "is required with interval")
+		default:
+			if filter.To.IsZero() {
+				filter.To = time.Now().UTC()
+			}
+			if filter.To.Sub(filter.From.Truncate(step)) > maxStatisticsBuckets*step {
+				validation.Add("interval", fmt.Sprintf("must split the window into at most %d buckets", maxStatisticsBuckets))
+			}
+		}
+	}
+
+	return filter, validation.Err()
+}
+
+// parseWindow reads the from and to creation time bounds as UTC, the zone the repository stores timestamps in.
+func parseWindow(query url.Values, validation *domain.ValidationError) (from, to time.Time) {
+	parseTime := func(name string) time.Time {
+		v := query.Get(name)
+		if v == "" {
+			return time.Time{}
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			validation.Add(name, "must be a RFC 3339 timestamp")
+			return time.Time{}
+		}
+		return t.UTC()
+	}
+	from = parseTime("from")
+	to = parseTime("to")
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		validation.Add("to", "must be after from")
+	}
+
+	return from, to
+}
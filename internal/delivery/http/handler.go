@@ -1,85 +1,216 @@
 package http
 
 import (
-	kafkaService "TransactiStream/internal/delivery/kafka"
 	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
 	"TransactiStream/internal/logger"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 type Repository interface {
 	Create(ctx context.Context, trans *domain.Transaction) (string, error)
 	Read(ctx context.Context, id string) (*domain.Transaction, error)
 	Update(ctx context.Context, trans *domain.Transaction) error
-	ReadAll(ctx context.Context) ([]*domain.Transaction, error)
-	GetStatistics(ctx context.Context) (*domain.Statistics, error)
+	CreateBatch(ctx context.Context, transactions []*domain.Transaction) error
+	MarkUnpublished(ctx context.Context, ids []string) error
+	ReadAll(ctx context.Context, filter domain.TransactionFilter) ([]*domain.Transaction, error)
+	Export(ctx context.Context, filter domain.TransactionFilter, fn func(*domain.Transaction) error) error
+	GetStatistics(ctx context.Context, filter domain.StatisticsFilter) (*domain.Statistics, error)
+}
+
+// Publisher sends stored transactions for processing.
+type Publisher interface {
+	SendMessage(ctx context.Context, trans *domain.Transaction) error
+	SendMessages(ctx context.Context, transactions []*domain.Transaction) []error
+}
+
+// Limits bound the size of request bodies and how long a request may wait for a processing result.
+// PendingTimeout is how long a published transaction may stay unprocessed before statistics
+// count it as timed out; zero never does.
+type Limits struct {
+	MaxBodyBytes      int64
+	MaxBatchItems     int
+	MaxBatchBodyBytes int64
+	MaxWait           time.Duration
+	PendingTimeout    time.Duration
 }
 
 type Handler struct {
-	repo     Repository
-	kafkaSrv *kafkaService.KafkaService
+	repo      Repository
+	kafkaSrv  Publisher
+	hub       *events.Hub
+	validator *domain.Validator
+	limits    Limits
 }
 
-func NewHandler(repo Repository, kafka *kafkaService.KafkaService) *Handler {
+func NewHandler(repo Repository, kafka Publisher, hub *events.Hub, validator *domain.Validator, limits Limits) *Handler {
 	return &Handler{
-		repo:     repo,
-		kafkaSrv: kafka,
+		repo:      repo,
+		kafkaSrv:  kafka,
+		hub:       hub,
+		validator: validator,
+		limits:    limits,
 	}
 }
 
+// createTransactionRequest is the body of POST /transaction.
+// Server-assigned fields are decoded only to reject them.
+type createTransactionRequest struct {
+	UserID    string    `json:"user_id"`
+	Amount    float64   `json:"amount"`
+	Currency  string    `json:"currency"`
+	Timestamp time.Time `json:"timestamp"`
+
+	ID          json.RawMessage `json:"id"`
+	Done        json.RawMessage `json:"done"`
+	Status      json.RawMessage `json:"status"`
+	ProcessedAt json.RawMessage `json:"processed_at"`
+}
+
+// toTransaction converts req to a new transaction, adding every problem found to errs.
+func (req *createTransactionRequest) toTransaction(v *domain.Validator, errs *domain.ValidationError) *domain.Transaction {
+	readOnly := []struct {
+		field string
+		raw   json.RawMessage
+	}{
+		{"id", req.ID},
+		{"done", req.Done},
+		{"status", req.Status},
+		{"processed_at", req.ProcessedAt},
+	}
+	for _, f := range readOnly {
+		if f.raw != nil {
+			errs.Add(f.field, "is read-only")
+		}
+	}
+
+	trans := &domain.Transaction{
+		UserID:    req.UserID,
+		Amount:    req.Amount,
+		Currency:  strings.ToUpper(req.Currency),
+		Timestamp: req.Timestamp,
+	}
+	v.Validate(trans, "", errs)
+
+	return trans
+}
+
+// CreateTransaction stores and publishes a transaction. With ?wait it responds once the
+// processing result is stored or the wait is over, whichever comes first.
 func (h *Handler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
 	var (
-		trans *domain.Transaction
-		err   error
-		ctx   = r.Context()
+		req = &createTransactionRequest{}
+		err error
+		ctx = r.Context()
 	)
 
-	if err = json.NewDecoder(r.Body).Decode(&trans); err != nil {
-		logger.Errorf("Error decoding request: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	wait, err := parseWait(r, h.limits.MaxWait)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if trans.UserID == "" || trans.Currency == "" || trans.Amount == 0 {
-		logger.Error("One of the fields is empty")
-		http.Error(w, "One of the fields is empty", http.StatusBadRequest)
+	if err = decodeJSON(w, r, h.limits.MaxBodyBytes, req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	validation := &domain.ValidationError{}
+	trans := req.toTransaction(h.validator, validation)
+	if err = validation.Err(); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	if trans.ID, err = h.repo.Create(ctx, trans); err != nil {
-		logger.Errorf("Error creating transaction: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, fmt.Errorf("failed to create transaction: %w", err))
 		return
 	}
+	ctx = logger.WithTransactionID(ctx, trans.ID)
+
+	// subscribed before publishing, so the result can't arrive unnoticed
+	var sub *events.Subscription
+	if wait > 0 {
+		sub = h.hub.Subscribe(domain.EventFilter{TransactionIDs: []string{trans.ID}}, waitBuffer)
+		defer sub.Close()
+	}
 
 	if err = h.kafkaSrv.SendMessage(ctx, trans); err != nil {
-		logger.Errorf("Error sending message to Kafka: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.markUnpublished(ctx, []string{trans.ID})
+		writeError(w, r.WithContext(ctx), fmt.Errorf("failed to send message to Kafka: %w", err))
+		return
+	}
+
+	if sub != nil {
+		awaitResult(ctx, sub, trans, wait)
+	}
+
+	w.Header().Set("Location", transactionURL(trans.ID))
+	writeJSON(w, r, http.StatusCreated, trans)
+}
+
+// GetTransaction returns a transaction. With ?wait a pending transaction is returned once
+// its processing result is stored or the wait is over, whichever comes first.
+func (h *Handler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+
+	wait, err := parseWait(r, h.limits.MaxWait)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	trans, err := h.repo.Read(ctx, id)
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to read transaction: %w", err))
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	if wait > 0 && trans.Status == domain.StatusPending {
+		// events carry the stored ID, which may be spelled differently from the path;
+		// reading again after subscribing catches a result stored in between
+		sub := h.hub.Subscribe(domain.EventFilter{TransactionIDs: []string{trans.ID}}, waitBuffer)
+		defer sub.Close()
+
+		if trans, err = h.repo.Read(ctx, trans.ID); err != nil {
+			writeError(w, r, fmt.Errorf("failed to read transaction: %w", err))
+			return
+		}
+		if trans.Status == domain.StatusPending {
+			awaitResult(ctx, sub, trans, wait)
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, trans)
 }
 
 func (h *Handler) GetAllTransactions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	transactions, err := h.repo.ReadAll(ctx)
+	filter, err := parseFilter(r)
 	if err != nil {
-		logger.Errorf("Error reading all transactions: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	if err = json.NewEncoder(w).Encode(transactions); err != nil {
-		logger.Errorf("Error encoding transactions: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	transactions, err := h.repo.ReadAll(ctx, filter)
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to read all transactions: %w", err))
 		return
 	}
+
+	writeJSON(w, r, http.StatusOK, transactions)
 }
 
+// GetStatistics aggregates transactions overall and per currency, optionally in one ?currency,
+// within ?from and ?to and as a series of ?interval buckets.
 func (h *Handler) GetStatistics(w http.ResponseWriter, r *http.Request) {
 	var (
 		stats *domain.Statistics
@@ -87,15 +218,28 @@ func (h *Handler) GetStatistics(w http.ResponseWriter, r *http.Request) {
 		err   error
 	)
 
-	if stats, err = h.repo.GetStatistics(ctx); err != nil {
-		logger.Errorf("Error getting statistics: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	filter, err := parseStatisticsFilter(r, h.limits.PendingTimeout)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err = json.NewEncoder(w).Encode(stats); err != nil {
-		logger.Errorf("Error encoding statistics: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if stats, err = h.repo.GetStatistics(ctx, filter); err != nil {
+		writeError(w, r, fmt.Errorf("failed to get statistics: %w", err))
 		return
 	}
+
+	writeJSON(w, r, http.StatusOK, stats)
+}
+
+// markUnpublished records that the transactions with ids could not be published. The request
+// already failed for that reason, so a failure to record it is only logged.
+func (h *Handler) markUnpublished(ctx context.Context, ids []string) {
+	if err := h.repo.MarkUnpublished(ctx, ids); err != nil {
+		logger.FromContext(ctx).Error().Err(err).Strs("ids", ids).Msg("failed to mark transactions as unpublished")
+	}
+}
+
+func transactionURL(id string) string {
+	return apiPrefix + "/transactions/" + url.PathEscape(id)
 }
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateTransaction_ValidationErrors(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/transaction", strings.NewReader(`{"amount": 10}`))
+	newTestRouter().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	var body struct {
+		Error struct {
+			Code    string              `json:"code"`
+			Details []domain.FieldError `json:"details"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, CodeValidation, body.Error.Code)
+	assert.Equal(t, []domain.FieldError{
+		{Field: "user_id", Message: "is required"},
+		{Field: "currency", Message: "is required"},
+	}, body.Error.Details)
+}
+
+func TestCreateTransaction_MalformedJSONHidesDetails(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/transaction", strings.NewReader(`{"amount": `))
+	newTestRouter().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var body ErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, CodeBadRequest, body.Error.Code)
+	assert.NotContains(t, body.Error.Message, "EOF")
+}
+
+func TestCreateTransaction_ReturnsCreatedResource(t *testing.T) {
+	router := newTestRouter()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/transaction",
+		strings.NewReader(`{"user_id": "user1", "amount": 10.5, "currency": "usd"}`))
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/v1/transactions/id-1", rec.Header().Get("Location"))
+
+	var created domain.Transaction
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, "id-1", created.ID)
+	assert.Equal(t, domain.StatusPending, created.Status)
+	assert.Equal(t, 10.5, created.Amount)
+	assert.Equal(t, "USD", created.Currency)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/transactions/id-1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/transactions/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCreateTransaction_RejectsReadOnlyFields(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/transaction",
+		strings.NewReader(`{"id": "x", "done": true, "user_id": "user1", "amount": 1, "currency": "USD"}`))
+	newTestRouter().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `{"field":"id","message":"is read-only"}`)
+	assert.Contains(t, rec.Body.String(), `{"field":"done","message":"is read-only"}`)
+}
+
+func TestCreateTransaction_StrictDecoding(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{"unknown field", `{"user_id": "u", "amount": 1, "currency": "USD", "note": "x"}`,
+			http.StatusUnprocessableEntity, `{"field":"note","message":"is not allowed"}`},
+		{"wrong type", `{"user_id": "u", "amount": "1", "currency": "USD"}`,
+			http.StatusUnprocessableEntity, `{"field":"amount","message":"must be a number"}`},
+		{"trailing garbage", `{"user_id": "u", "amount": 1, "currency": "USD"} {}`,
+			http.StatusBadRequest, `"code":"bad_request"`},
+		{"too large", `{"user_id": "` + strings.Repeat("a", 2048) + `"}`,
+			http.StatusRequestEntityTooLarge, `"code":"payload_too_large"`},
+		{"negative amount", `{"user_id": "u", "amount": -1, "currency": "USD"}`,
+			http.StatusUnprocessableEntity, `{"field":"amount","message":"must be positive"}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/transaction", strings.NewReader(tc.body)))
+
+			assert.Equal(t, tc.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), tc.want)
+		})
+	}
+}
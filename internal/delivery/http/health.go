@@ -0,0 +1,123 @@
+package http
+
+import (
+	"TransactiStream/internal/logger"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const readinessTimeout = 3 * time.Second
+
+// CheckFunc reports the state of a single dependency; nil means healthy.
+type CheckFunc func(ctx context.Context) error
+
+type checkResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+type HealthHandler struct {
+	checks map[string]CheckFunc
+	// timeout bounds a readiness probe; a check still running then fails.
+	timeout time.Duration
+}
+
+func NewHealthHandler(checks map[string]CheckFunc) *HealthHandler {
+	return &HealthHandler{
+		checks:  checks,
+		timeout: readinessTimeout,
+	}
+}
+
+// BacklogCheck fails when more than max items of what are waiting. count need not count
+// beyond limit.
+func BacklogCheck(what string, max int, count func(ctx context.Context, limit int) (int, error)) CheckFunc {
+	return func(ctx context.Context) error {
+		n, err := count(ctx, max+1)
+		if err != nil {
+			return err
+		}
+		if n > max {
+			return fmt.Errorf("more than %d %s waiting", max, what)
+		}
+		return nil
+	}
+}
+
+// Liveness only tells that the process is up and serving HTTP.
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(r.Context(), w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Readiness runs every registered check concurrently and reports 503 if any of them fails.
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		resp = healthResponse{
+			Status: "ok",
+			Checks: make(map[string]checkResult, len(h.checks)),
+		}
+	)
+
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check CheckFunc) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			res := checkResult{Status: "ok", Duration: time.Since(start).String()}
+			if err != nil {
+				res.Status = "error"
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			resp.Checks[name] = res
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	names := make([]string, 0, len(resp.Checks))
+	for name := range resp.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if res := resp.Checks[name]; res.Status != "ok" {
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			logger.FromContext(ctx).Warn().Str("check", name).Str("error", res.Error).Msg("Readiness check failed")
+		}
+	}
+
+	writeHealth(ctx, w, status, resp)
+}
+
+func writeHealth(ctx context.Context, w http.ResponseWriter, status int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.FromContext(ctx).Error().Err(err).Msg("Error encoding health response")
+	}
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLiveness(t *testing.T) {
+	h := NewHealthHandler(map[string]CheckFunc{
+		"postgres": func(context.Context) error { return errors.New("down") },
+	})
+
+	rec := httptest.NewRecorder()
+	h.Liveness(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestReadiness(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	cases := map[string]struct {
+		checks     map[string]CheckFunc
+		wantCode   int
+		wantStatus string
+		wantChecks map[string]checkResult
+	}{
+		"all healthy": {
+			checks:     map[string]CheckFunc{"postgres": ok, "kafka": ok},
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+			wantChecks: map[string]checkResult{"postgres": {Status: "ok"}, "kafka": {Status: "ok"}},
+		},
+		"failing check": {
+			checks: map[string]CheckFunc{
+				"postgres": ok,
+				"kafka":    func(context.Context) error { return errors.New("failed to dial broker") },
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "unavailable",
+			wantChecks: map[string]checkResult{
+				"postgres": {Status: "ok"},
+				"kafka":    {Status: "error", Error: "failed to dial broker"},
+			},
+		},
+		"check exceeds the timeout": {
+			checks:     map[string]CheckFunc{"postgres": slow},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "unavailable",
+			wantChecks: map[string]checkResult{
+				"postgres": {Status: "error", Error: context.DeadlineExceeded.Error()},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := NewHealthHandler(c.checks)
+			h.timeout = 50 * time.Millisecond
+
+			rec := httptest.NewRecorder()
+			h.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, c.wantCode, rec.Code)
+			assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+			var body healthResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, c.wantStatus, body.Status)
+			for name, res := range body.Checks {
+				assert.NotEmpty(t, res.Duration)
+				res.Duration = ""
+				body.Checks[name] = res
+			}
+			assert.Equal(t, c.wantChecks, body.Checks)
+		})
+	}
+}
+
+func TestBacklogCheck(t *testing.T) {
+	var limit int
+	count := func(n int) func(context.Context, int) (int, error) {
+		return func(_ context.Context, l int) (int, error) {
+			limit = l
+			return min(n, l), nil
+		}
+	}
+	ctx := context.Background()
+
+	assert.NoError(t, BacklogCheck("due deliveries", 10, count(10))(ctx))
+	assert.Equal(t, 11, limit)
+	assert.EqualError(t, BacklogCheck("due deliveries", 10, count(500))(ctx), "more than 10 due deliveries waiting")
+
+	failing := func(context.Context, int) (int, error) { return 0, errors.New("connection refused") }
+	assert.EqualError(t, BacklogCheck("due deliveries", 10, failing)(ctx), "connection refused")
+}
@@ -0,0 +1,273 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/importer"
+	"TransactiStream/internal/logger"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// importHeartbeat is how often a running import is marked as alive.
+	importHeartbeat = 30 * time.Second
+	// importStaleAfter is how long a running import may go unmarked before it is taken for one
+	// left behind by a stopped instance and failed.
+	importStaleAfter = 4 * importHeartbeat
+)
+
+type ImportRepository interface {
+	CreateImport(ctx context.Context, imp *domain.Import) error
+	SaveImportProgress(ctx context.Context, imp *domain.Import) error
+	TouchImport(ctx context.Context, id string) error
+	FailStaleImports(ctx context.Context, before time.Time) (int, error)
+	FinishImport(ctx context.Context, imp *domain.Import) error
+	ReadImport(ctx context.Context, id string) (*domain.Import, error)
+	AddImportErrors(ctx context.Context, importID string, errs []domain.ImportError) error
+	ReadImportErrors(ctx context.Context, importID string, fn func(domain.ImportError) error) error
+}
+
+type ImportHandler struct {
+	repo         ImportRepository
+	importer     *importer.Importer
+	maxBodyBytes int64
+	// slots holds a token per import running in the background.
+	slots chan struct{}
+}
+
+// NewImportHandler runs at most maxConcurrent imports at a time.
+func NewImportHandler(repo ImportRepository, importer *importer.Importer, maxBodyBytes int64, maxConcurrent int) *ImportHandler {
+	return &ImportHandler{
+		repo:         repo,
+		importer:     importer,
+		maxBodyBytes: maxBodyBytes,
+		slots:        make(chan struct{}, maxConcurrent),
+	}
+}
+
+// formatsByContentType lets clients pick the format with Content-Type instead of ?format.
+var formatsByContentType = map[string]string{
+	"text/csv":             importer.FormatCSV,
+	"application/x-ndjson": importer.FormatNDJSON,
+	"application/ndjson":   importer.FormatNDJSON,
+}
+
+// CreateImport saves the request body to a temporary file, responds 202 Accepted with the
+// import to poll and loads the file in the background. Rejected lines are kept for GetImportErrors.
+// While as many imports as allowed are running, it responds 429 Too Many Requests.
+func (h *ImportHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	var (
+		imp = &domain.Import{}
+		err error
+		ctx = r.Context()
+	)
+
+	validation := &domain.ValidationError{}
+	query := r.URL.Query()
+
+	imp.Format = query.Get("format")
+	if imp.Format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		imp.Format = formatsByContentType[mediaType]
+	}
+	if imp.Format != importer.FormatCSV && imp.Format != importer.FormatNDJSON {
+		validation.Add("format", fmt.Sprintf("must be %s or %s", importer.FormatCSV, importer.FormatNDJSON))
+	}
+	if v := query.Get("skip_publish"); v != "" {
+		if imp.SkipPublish, err = strconv.ParseBool(v); err != nil {
+			validation.Add("skip_publish", "must be a boolean")
+		}
+	}
+	if err = validation.Err(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	select {
+	case h.slots <- struct{}{}:
+	default:
+		w.Header().Set("Retry-After", strconv.Itoa(int(importHeartbeat.Seconds())))
+		writeError(w, r, &APIError{Status: http.StatusTooManyRequests, Code: CodeTooManyRequests, Message: "too many imports are running"})
+		return
+	}
+
+	// the body can only be read while the request is served
+	file, err := h.spool(w, r)
+	if err != nil {
+		<-h.slots
+		writeError(w, r, err)
+		return
+	}
+
+	if err = h.repo.CreateImport(ctx, imp); err != nil {
+		<-h.slots
+		removeTemp(file)
+		writeError(w, r, fmt.Errorf("failed to create import: %w", err))
+		return
+	}
+	ctx = logger.With(ctx, func(c zerolog.Context) zerolog.Context { return c.Str("import_id", imp.ID) })
+
+	accepted := *imp
+	go h.run(context.WithoutCancel(ctx), file, imp)
+
+	w.Header().Set("Location", importURL(accepted.ID))
+	writeJSON(w, r, http.StatusAccepted, &accepted)
+}
+
+// spool copies the request body of at most maxBodyBytes to a temporary file positioned at its start.
+func (h *ImportHandler) spool(w http.ResponseWriter, r *http.Request) (*os.File, error) {
+	file, err := os.CreateTemp("", "import-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	_, err = io.Copy(file, http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		removeTemp(file)
+
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, decodeError(err)
+		}
+		return nil, badRequest("request body could not be read", err)
+	}
+
+	return file, nil
+}
+
+// run loads the spooled file into imp, saving its progress after every chunk, records the
+// outcome and frees the slot taken by CreateImport.
+func (h *ImportHandler) run(ctx context.Context, file *os.File, imp *domain.Import) {
+	defer func() { <-h.slots }()
+	defer removeTemp(file)
+
+	// a chunk can take longer than importStaleAfter, so liveness is not left to progress alone
+	done := make(chan struct{})
+	defer close(done)
+	go h.heartbeat(ctx, imp.ID, done)
+
+	err := h.importer.Run(ctx, file, imp, func(ctx context.Context, errs []domain.ImportError) error {
+		return h.repo.AddImportErrors(ctx, imp.ID, errs)
+	}, h.repo.SaveImportProgress)
+
+	imp.Status = domain.ImportCompleted
+	if err != nil {
+		imp.Status = domain.ImportFailed
+		imp.Error = toAPIError(err).Message
+	}
+
+	if finishErr := h.repo.FinishImport(ctx, imp); finishErr != nil {
+		logger.FromContext(ctx).Error().Err(finishErr).Msg("Error finishing import")
+	}
+
+	event := logger.FromContext(ctx).Info()
+	if err != nil {
+		event = logger.FromContext(ctx).Error().Err(err)
+	}
+	event.
+		Str("status", string(imp.Status)).
+		Int("total", imp.Total).
+		Int("imported", imp.Imported).
+		Int("rejected", imp.Rejected).
+		Int("publish_failed", imp.PublishFailed).
+		Msg("Import finished")
+}
+
+// heartbeat marks the import with id as alive every importHeartbeat until done is closed.
+func (h *ImportHandler) heartbeat(ctx context.Context, id string, done <-chan struct{}) {
+	ticker := time.NewTicker(importHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := h.repo.TouchImport(ctx, id); err != nil {
+				logger.FromContext(ctx).Warn().Err(err).Msg("Error marking import as alive")
+			}
+		}
+	}
+}
+
+// FailStaleImports fails the imports left running by a stopped instance, on startup and then
+// every importHeartbeat until ctx is done.
+func (h *ImportHandler) FailStaleImports(ctx context.Context) {
+	ticker := time.NewTicker(importHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		n, err := h.repo.FailStaleImports(ctx, time.Now().Add(-importStaleAfter))
+		switch {
+		case err != nil:
+			logger.FromContext(ctx).Error().Err(err).Msg("Error failing stale imports")
+		case n > 0:
+			logger.FromContext(ctx).Warn().Int("imports", n).Msg("Failed imports left running by a stopped instance")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *ImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	imp, err := h.repo.ReadImport(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to read import: %w", err))
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, imp)
+}
+
+// GetImportErrors downloads the rejected lines of an import as CSV.
+func (h *ImportHandler) GetImportErrors(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	imp, err := h.repo.ReadImport(ctx, r.PathValue("id"))
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to read import: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="import-`+imp.ID+`-errors.csv"`)
+
+	report, err := importer.NewCSVReport(w)
+	if err == nil {
+		err = h.repo.ReadImportErrors(ctx, imp.ID, func(e domain.ImportError) error {
+			return report.Write([]domain.ImportError{e})
+		})
+	}
+	if err == nil {
+		err = report.Flush()
+	}
+	if err != nil {
+		// the status line is already sent, so the truncated body is all the client gets
+		logger.FromContext(ctx).Error().Err(err).Str("import_id", imp.ID).Msg("Error writing import report")
+	}
+}
+
+func removeTemp(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
+
+func importURL(id string) string {
+	return apiPrefix + "/imports/" + url.PathEscape(id)
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeImportRepo stores copies of imports, as a database would, since they are finished in the background.
+type fakeImportRepo struct {
+	mu      sync.Mutex
+	imports map[string]domain.Import
+	errors  []domain.ImportError
+	// staleBefore is the cutoff of the last FailStaleImports; stale are the imports it fails.
+	staleBefore time.Time
+	stale       int
+}
+
+func (f *fakeImportRepo) CreateImport(ctx context.Context, imp *domain.Import) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	imp.ID = "import-1"
+	imp.Status = domain.ImportRunning
+	f.imports[imp.ID] = *imp
+	return nil
+}
+
+func (f *fakeImportRepo) SaveImportProgress(ctx context.Context, imp *domain.Import) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.imports[imp.ID] = *imp
+	return nil
+}
+
+func (f *fakeImportRepo) TouchImport(ctx context.Context, id string) error {
+	return nil
+}
+
+func (f *fakeImportRepo) FailStaleImports(ctx context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.staleBefore = before
+	return f.stale, nil
+}
+
+func (f *fakeImportRepo) FinishImport(ctx context.Context, imp *domain.Import) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.imports[imp.ID] = *imp
+	return nil
+}
+
+func (f *fakeImportRepo) ReadImport(ctx context.Context, id string) (*domain.Import, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	imp, ok := f.imports[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &imp, nil
+}
+
+func (f *fakeImportRepo) AddImportErrors(ctx context.Context, importID string, errs []domain.ImportError) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.errors = append(f.errors, errs...)
+	return nil
+}
+
+func (f *fakeImportRepo) ReadImportErrors(ctx context.Context, importID string, fn func(domain.ImportError) error) error {
+	f.mu.Lock()
+	errs := append([]domain.ImportError(nil), f.errors...)
+	f.mu.Unlock()
+
+	for _, e := range errs {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCreateImport_CSV(t *testing.T) {
+	router := newTestRouter()
+
+	body := "user_id,amount,currency\n" +
+		"u1,10,USD\n" +
+		"u2,ten,USD\n" +
+		"u3,30,EUR\n"
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/imports", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "/v1/imports/import-1", rec.Header().Get("Location"))
+
+	var imp domain.Import
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &imp))
+	assert.Equal(t, domain.ImportRunning, imp.Status)
+
+	require.Eventually(t, func() bool {
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/imports/import-1", nil))
+		imp = domain.Import{}
+		return assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &imp)) && imp.Status != domain.ImportRunning
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, domain.ImportCompleted, imp.Status)
+	assert.Equal(t, 3, imp.Total)
+	assert.Equal(t, 2, imp.Imported)
+	assert.Equal(t, 1, imp.Rejected)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/imports/import-1/errors", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "line,field,message,raw\n3,amount,must be a number,\"u2,ten,USD\"\n", rec.Body.String())
+}
+
+func TestCreateImport_InvalidParameters(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/imports?format=xml&skip_publish=maybe", strings.NewReader("")))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	var body struct {
+		Error struct {
+			Details []domain.FieldError `json:"details"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, []domain.FieldError{
+		{Field: "format", Message: "must be csv or ndjson"},
+		{Field: "skip_publish", Message: "must be a boolean"},
+	}, body.Error.Details)
+}
+
+func TestCreateImport_TooLarge(t *testing.T) {
+	body := "user_id,amount,currency\n" + strings.Repeat("u1,10,USD\n", 500)
+
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/imports?format=csv", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestCreateImport_TooManyRunning(t *testing.T) {
+	h := NewImportHandler(&fakeImportRepo{imports: map[string]domain.Import{}}, nil, 4096, 1)
+	h.slots <- struct{}{}
+
+	rec := httptest.NewRecorder()
+	h.CreateImport(rec, httptest.NewRequest(http.MethodPost, "/v1/imports?format=csv", strings.NewReader("user_id,amount,currency\n")))
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), `"code":"too_many_requests"`)
+}
+
+func TestFailStaleImports(t *testing.T) {
+	repo := &fakeImportRepo{imports: map[string]domain.Import{}, stale: 1}
+	h := NewImportHandler(repo, nil, 4096, 1)
+
+	// a done context still fails the stale imports once, as on startup
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h.FailStaleImports(ctx)
+
+	assert.WithinDuration(t, time.Now().Add(-importStaleAfter), repo.staleBefore, time.Second)
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"TransactiStream/internal/logger"
+	"TransactiStream/internal/metrics"
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestRequestID_PropagatesHeader(t *testing.T) {
+	var seen string
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logger.RequestID(r.Context())
+	}), RequestID)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "abc-123", seen)
+	assert.Equal(t, "abc-123", rec.Header().Get(requestIDHeader))
+}
+
+func TestRequestID_ReplacesInvalidHeader(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), RequestID)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIDHeader, "bad id\n")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	id := rec.Header().Get(requestIDHeader)
+	assert.NotEmpty(t, id)
+	assert.NotEqual(t, "bad id\n", id)
+}
+
+func TestRecoverer_ReturnsJSON500(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}), RequestID, AccessLog, Recoverer)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body ErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, CodeInternal, body.Error.Code)
+	assert.Equal(t, "req-1", body.Error.RequestID)
+}
+
+func TestInstrument_LabelsRouteAndStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		code    string
+	}{
+		{name: "implicit 200", handler: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }, code: "200"},
+		{name: "explicit status", handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }, code: "418"},
+		{name: "first status wins", handler: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			w.WriteHeader(http.StatusInternalServerError)
+		}, code: "202"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := "GET /instrument/" + tt.code
+			requests := metrics.HTTPRequests.WithLabelValues(route, http.MethodGet, tt.code)
+			before := testutil.ToFloat64(requests)
+
+			Instrument(route, tt.handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, before+1, testutil.ToFloat64(requests))
+		})
+	}
+}
+
+func TestRouter_InstrumentsRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		route  string
+		code   string
+	}{
+		{name: "matched", method: http.MethodGet, path: "/v1/statistics", route: "GET /v1/statistics", code: "200"},
+		{name: "not found", method: http.MethodGet, path: "/v1/no-such-route", route: unmatchedRoute, code: "404"},
+		{name: "method not allowed", method: http.MethodDelete, path: "/v1/statistics", route: unmatchedRoute, code: "405"},
+	}
+
+	router := newTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := metrics.HTTPRequests.WithLabelValues(tt.route, tt.method, tt.code)
+			before := testutil.ToFloat64(requests)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.code, strconv.Itoa(rec.Code))
+			assert.Equal(t, before+1, testutil.ToFloat64(requests))
+		})
+	}
+}
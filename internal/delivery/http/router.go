@@ -0,0 +1,109 @@
+package http
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"net/http"
+)
+
+const apiPrefix = "/v1"
+
+// NewRouter registers the versioned API, its deprecated unversioned aliases
+// and the operational endpoints on a dedicated mux.
+func NewRouter(h *Handler, imports *ImportHandler, stream *StreamHandler, webhooks *WebhookHandler, health *HealthHandler, metrics http.Handler) http.Handler {
+	mux := http.NewServeMux()
+
+	api := []struct {
+		method  string
+		path    string
+		handler http.HandlerFunc
+	}{
+		{http.MethodPost, "/transaction", h.CreateTransaction},
+		{http.MethodGet, "/transactions", h.GetAllTransactions},
+		{http.MethodGet, "/statistics", h.GetStatistics},
+	}
+
+	for _, route := range api {
+		handle(mux, route.method+" "+apiPrefix+route.path, route.handler)
+		handle(mux, route.method+" "+route.path, deprecated(apiPrefix+route.path, route.handler))
+	}
+
+	handle(mux, "POST "+apiPrefix+"/transactions/batch", http.HandlerFunc(h.CreateTransactions))
+	handle(mux, "GET "+apiPrefix+"/transactions/export", http.HandlerFunc(h.ExportTransactions))
+	handle(mux, "GET "+apiPrefix+"/transactions/stream", http.HandlerFunc(stream.StreamEvents))
+	handle(mux, "GET "+apiPrefix+"/transactions/ws", http.HandlerFunc(stream.Subscribe))
+	handle(mux, "GET "+apiPrefix+"/transactions/{id}", http.HandlerFunc(h.GetTransaction))
+
+	handle(mux, "POST "+apiPrefix+"/imports", http.HandlerFunc(imports.CreateImport))
+	handle(mux, "GET "+apiPrefix+"/imports/{id}", http.HandlerFunc(imports.GetImport))
+	handle(mux, "GET "+apiPrefix+"/imports/{id}/errors", http.HandlerFunc(imports.GetImportErrors))
+
+	handle(mux, "POST "+apiPrefix+"/webhooks", http.HandlerFunc(webhooks.CreateWebhook))
+	handle(mux, "GET "+apiPrefix+"/webhooks", http.HandlerFunc(webhooks.ListWebhooks))
+	handle(mux, "DELETE "+apiPrefix+"/webhooks/{id}", http.HandlerFunc(webhooks.DeleteWebhook))
+	handle(mux, "GET "+apiPrefix+"/webhooks/{id}/deliveries", http.HandlerFunc(webhooks.ListDeliveries))
+	handle(mux, "POST "+apiPrefix+"/webhooks/{id}/deliveries/{delivery_id}/redeliver", http.HandlerFunc(webhooks.Redeliver))
+
+	handle(mux, "GET /healthz", http.HandlerFunc(health.Liveness))
+	handle(mux, "GET /readyz", http.HandlerFunc(health.Readiness))
+	mux.Handle("GET /metrics", metrics)
+
+	return jsonFallback(mux)
+}
+
+// unmatchedRoute labels the metrics of requests that match no route, keeping the label set bounded.
+const unmatchedRoute = "unmatched"
+
+// jsonFallback renders the mux's own 404 and 405 responses as ErrorResponse.
+func jsonFallback(mux *http.ServeMux) http.Handler {
+	unmatched := Instrument(unmatchedRoute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// let the mux decide between 404 and 405 and fill in Allow, then replace its text body
+		probe := &headerProbe{header: w.Header()}
+		mux.ServeHTTP(probe, r)
+
+		if probe.status == http.StatusMethodNotAllowed {
+			writeError(w, r, &APIError{Status: http.StatusMethodNotAllowed, Code: CodeMethodNotAllowed, Message: "method not allowed"})
+			return
+		}
+		writeError(w, r, &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "route not found"})
+	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		unmatched.ServeHTTP(w, r)
+	})
+}
+
+// headerProbe captures the status and headers of a response and discards its body.
+type headerProbe struct {
+	header http.Header
+	status int
+}
+
+func (p *headerProbe) Header() http.Header {
+	return p.header
+}
+
+func (p *headerProbe) WriteHeader(status int) {
+	p.status = status
+}
+
+func (p *headerProbe) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// handle registers next under pattern with metrics and tracing labelled by the pattern.
+func handle(mux *http.ServeMux, pattern string, next http.Handler) {
+	mux.Handle(pattern, otelhttp.NewHandler(Instrument(pattern, next), pattern))
+}
+
+// deprecated marks responses of an unversioned alias and points clients to its successor.
+func deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,204 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
+	"TransactiStream/internal/importer"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeRepo struct {
+	stats *domain.Statistics
+	// statsFilter is the filter of the last GetStatistics call.
+	statsFilter  domain.StatisticsFilter
+	transactions map[string]*domain.Transaction
+	exportErr    error
+	// unpublished are the IDs passed to MarkUnpublished.
+	unpublished []string
+}
+
+func (f *fakeRepo) Create(ctx context.Context, trans *domain.Transaction) (string, error) {
+	trans.ID = "id-1"
+	trans.Status = domain.StatusPending
+	f.transactions[trans.ID] = trans
+	return trans.ID, nil
+}
+
+func (f *fakeRepo) Read(ctx context.Context, id string) (*domain.Transaction, error) {
+	// UUIDs are matched regardless of case, as in Postgres
+	trans, ok := f.transactions[strings.ToLower(id)]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return trans, nil
+}
+
+func (f *fakeRepo) Update(ctx context.Context, trans *domain.Transaction) error {
+	return nil
+}
+
+func (f *fakeRepo) CreateBatch(ctx context.Context, transactions []*domain.Transaction) error {
+	for _, trans := range transactions {
+		trans.ID = fmt.Sprintf("id-%d", len(f.transactions)+1)
+		trans.Status = domain.StatusPending
+		f.transactions[trans.ID] = trans
+	}
+	return nil
+}
+
+func (f *fakeRepo) MarkUnpublished(ctx context.Context, ids []string) error {
+	f.unpublished = append(f.unpublished, ids...)
+	return nil
+}
+
+func (f *fakeRepo) ReadAll(ctx context.Context, filter domain.TransactionFilter) ([]*domain.Transaction, error) {
+	return nil, nil
+}
+
+func (f *fakeRepo) Export(ctx context.Context, filter domain.TransactionFilter, fn func(*domain.Transaction) error) error {
+	if f.exportErr != nil {
+		return f.exportErr
+	}
+
+	ids := make([]string, 0, len(f.transactions))
+	for id := range f.transactions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if trans := f.transactions[id]; filter.Currency == "" || trans.Currency == filter.Currency {
+			if err := fn(trans); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) GetStatistics(ctx context.Context, filter domain.StatisticsFilter) (*domain.Statistics, error) {
+	f.statsFilter = filter
+	return f.stats, nil
+}
+
+type fakePublisher struct {
+	sent []*domain.Transaction
+	// onSend is called with every transaction passed to SendMessage.
+	onSend func(*domain.Transaction)
+	// fail makes SendMessages fail for transactions with these IDs.
+	fail map[string]bool
+}
+
+func (f *fakePublisher) SendMessage(ctx context.Context, trans *domain.Transaction) error {
+	f.sent = append(f.sent, trans)
+	if f.onSend != nil {
+		f.onSend(trans)
+	}
+	return nil
+}
+
+func (f *fakePublisher) SendMessages(ctx context.Context, transactions []*domain.Transaction) []error {
+	var errs []error
+	for i, trans := range transactions {
+		if f.fail[trans.ID] {
+			if errs == nil {
+				errs = make([]error, len(transactions))
+			}
+			errs[i] = domain.ErrUnavailable
+			continue
+		}
+		f.sent = append(f.sent, trans)
+	}
+	return errs
+}
+
+func newTestRouter() http.Handler {
+	return newTestRouterWith(&fakePublisher{})
+}
+
+func newTestRouterWith(publisher *fakePublisher) http.Handler {
+	return newTestRouterFrom(testDeps{publisher: publisher})
+}
+
+// testDeps are the fakes behind a test router; nil fields get fresh ones.
+type testDeps struct {
+	repo      *fakeRepo
+	publisher *fakePublisher
+	hub       *events.Hub
+	webhooks  *fakeWebhookRepo
+}
+
+func newTestRouterFrom(deps testDeps) http.Handler {
+	validator, _ := domain.NewValidator(domain.ValidationRules{
+		UserIDPattern: `[A-Za-z0-9_-]{1,64}`,
+		DefaultLimits: domain.AmountLimits{Min: 0.01, Max: 1000},
+	})
+
+	if deps.repo == nil {
+		deps.repo = &fakeRepo{
+			stats:        &domain.Statistics{},
+			transactions: map[string]*domain.Transaction{},
+		}
+	}
+	if deps.publisher == nil {
+		deps.publisher = &fakePublisher{}
+	}
+	if deps.hub == nil {
+		deps.hub = events.NewHub()
+	}
+	if deps.webhooks == nil {
+		deps.webhooks = newFakeWebhookRepo()
+	}
+
+	h := NewHandler(deps.repo, deps.publisher, deps.hub, validator,
+		Limits{MaxBodyBytes: 1024, MaxBatchItems: 3, MaxBatchBodyBytes: 4096, MaxWait: time.Second})
+	imports := NewImportHandler(&fakeImportRepo{imports: map[string]domain.Import{}},
+		importer.New(deps.repo, deps.publisher, validator, 2), 4096, 2)
+	stream := NewStreamHandler(&fakeEventRepo{}, deps.hub, nil)
+	webhooks := NewWebhookHandler(deps.webhooks, 1024)
+	return NewRouter(h, imports, stream, webhooks, NewHealthHandler(nil), http.NotFoundHandler())
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/transaction", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "POST", rec.Header().Get("Allow"))
+
+	var body ErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, CodeMethodNotAllowed, body.Error.Code)
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/unknown", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+}
+
+func TestRouter_VersionedAndDeprecatedPaths(t *testing.T) {
+	router := newTestRouter()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/statistics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/statistics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.Equal(t, `</v1/statistics>; rel="successor-version"`, rec.Header().Get("Link"))
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetStatistics_Window(t *testing.T) {
+	repo := &fakeRepo{stats: &domain.Statistics{}, transactions: map[string]*domain.Transaction{}}
+	router := newTestRouterFrom(testDeps{repo: repo})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/v1/statistics?from=2024-07-31T12:00:00%2B03:00&to=2024-08-01T00:00:00Z&interval=hour", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, domain.StatisticsFilter{
+		From:     time.Date(2024, 7, 31, 9, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC),
+		Interval: domain.IntervalHour,
+	}, repo.statsFilter)
+}
+
+func TestGetStatistics_InvalidWindow(t *testing.T) {
+	cases := map[string]string{
+		"unknown interval":  "/v1/statistics?from=2024-07-31T00:00:00Z&interval=week",
+		"interval w/o from": "/v1/statistics?interval=hour",
+		"too many buckets":  "/v1/statistics?from=2024-01-01T00:00:00Z&to=2024-07-01T00:00:00Z&interval=minute",
+		"reversed window":   "/v1/statistics?from=2024-08-01T00:00:00Z&to=2024-07-01T00:00:00Z",
+	}
+
+	for name, target := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		})
+	}
+}
+
+func TestGetStatistics_Currency(t *testing.T) {
+	repo := &fakeRepo{stats: &domain.Statistics{}, transactions: map[string]*domain.Transaction{}}
+	router := newTestRouterFrom(testDeps{repo: repo})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/statistics?currency=usdt", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, domain.StatisticsFilter{Currency: "USDT"}, repo.statsFilter)
+}
+
+func TestGetStatistics_ProcessingTime(t *testing.T) {
+	stats := &domain.Statistics{ProcessingTime: domain.ProcessingTimes{P50: 1.5, P90: 4, P95: 6, P99: 9.5, Max: 12, Estimated: true}}
+	repo := &fakeRepo{stats: stats, transactions: map[string]*domain.Transaction{}}
+
+	rec := httptest.NewRecorder()
+	newTestRouterFrom(testDeps{repo: repo}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/statistics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Contains(t, rec.Body.String(), `"processing_time":{"p50":1.5,"p90":4,"p95":6,"p99":9.5,"max":12,"estimated":true}`)
+}
+
+func TestGetStatistics_PendingTimeout(t *testing.T) {
+	repo := &fakeRepo{stats: &domain.Statistics{}, transactions: map[string]*domain.Transaction{}}
+	h := NewHandler(repo, &fakePublisher{}, events.NewHub(), nil, Limits{PendingTimeout: 5 * time.Minute})
+
+	rec := httptest.NewRecorder()
+	h.GetStatistics(rec, httptest.NewRequest(http.MethodGet, "/v1/statistics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.WithinDuration(t, time.Now().UTC().Add(-5*time.Minute), repo.statsFilter.TimedOutBefore, time.Minute)
+	assert.Equal(t, time.UTC, repo.statsFilter.TimedOutBefore.Location())
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
+	"TransactiStream/internal/logger"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// streamHeartbeat keeps idle connections from being closed by proxies.
+	streamHeartbeat = 15 * time.Second
+	// streamBuffer is how many events a client may lag behind before it is disconnected.
+	streamBuffer = 256
+	// streamRetry is the reconnection delay suggested to EventSource clients.
+	streamRetry = 3 * time.Second
+)
+
+type EventRepository interface {
+	ReadEvents(ctx context.Context, after int64, filter domain.EventFilter, fn func(domain.TransactionEvent) error) error
+}
+
+// StreamHandler serves live status changes over Server-Sent Events and WebSocket.
+type StreamHandler struct {
+	repo      EventRepository
+	hub       *events.Hub
+	upgrader  *websocket.Upgrader
+	heartbeat time.Duration
+}
+
+func NewStreamHandler(repo EventRepository, hub *events.Hub, allowedOrigins []string) *StreamHandler {
+	return &StreamHandler{
+		repo:      repo,
+		hub:       hub,
+		upgrader:  newUpgrader(allowedOrigins),
+		heartbeat: streamHeartbeat,
+	}
+}
+
+// StreamEvents sends status changes as Server-Sent Events, optionally only those of the
+// given user_id and transaction_id values. A client that reconnects with Last-Event-ID first
+// receives the events it missed from the event log, then live ones.
+func (h *StreamHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	filter := domain.EventFilter{UserIDs: query["user_id"], TransactionIDs: query["transaction_id"]}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = query.Get("last_event_id")
+	}
+	last := int64(-1)
+	if lastID != "" {
+		seq, err := strconv.ParseInt(lastID, 10, 64)
+		if err != nil || seq < 0 {
+			validation := &domain.ValidationError{}
+			validation.Add("Last-Event-ID", "must be a non-negative integer")
+			writeError(w, r, validation)
+			return
+		}
+		last = seq
+	}
+
+	// subscribe before replaying so that nothing published in between is lost
+	sub := h.hub.Subscribe(filter, streamBuffer)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	rc.Flush()
+
+	// seqs are assigned before commit, so events can be published out of seq order and the
+	// replay covers every event that may have committed after last; only the replayed ones
+	// are known to have been sent already
+	replayed := map[int64]struct{}{}
+	if last >= 0 {
+		err := h.repo.ReadEvents(ctx, last, filter, func(ev domain.TransactionEvent) error {
+			replayed[ev.Seq] = struct{}{}
+			last = ev.Seq
+			return writeEvent(w, ev)
+		})
+		if err != nil {
+			logger.FromContext(ctx).Error().Err(err).Msg("Failed to replay events")
+			return
+		}
+		rc.Flush()
+	}
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-sub.C:
+			if !ok {
+				logger.FromContext(ctx).Warn().Int64("last_event_id", last).Msg("Event stream fell behind, closing it")
+				return
+			}
+			if _, ok := replayed[ev.Seq]; ok {
+				delete(replayed, ev.Seq)
+				continue
+			}
+			// events arrive in commit order, so no replayed one follows an event committed after the replay
+			clear(replayed)
+			last = max(last, ev.Seq)
+			err = writeEvent(w, ev)
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			logger.FromContext(ctx).Debug().Err(err).Msg("Event stream closed")
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev domain.TransactionEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: status\ndata: %s\n\n", ev.Seq, data)
+	return err
+}
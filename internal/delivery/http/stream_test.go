@@ -0,0 +1,88 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeEventRepo struct {
+	events []domain.TransactionEvent
+}
+
+func (f *fakeEventRepo) ReadEvents(ctx context.Context, after int64, filter domain.EventFilter, fn func(domain.TransactionEvent) error) error {
+	for _, ev := range f.events {
+		if ev.Seq > after && filter.Match(ev) {
+			if err := fn(ev); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readEventIDs returns the ids of the next n events on the stream.
+func readEventIDs(t *testing.T, s *bufio.Scanner, n int) []string {
+	t.Helper()
+
+	var ids []string
+	for len(ids) < n && s.Scan() {
+		if id, ok := strings.CutPrefix(s.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	assert.NoError(t, s.Err())
+	return ids
+}
+
+func TestStreamEvents_ReplayThenLive(t *testing.T) {
+	hub := events.NewHub()
+	repo := &fakeEventRepo{events: []domain.TransactionEvent{
+		{Seq: 1, UserID: "u1", TransactionID: "t1"},
+		{Seq: 2, UserID: "u2", TransactionID: "t2"},
+		{Seq: 3, UserID: "u1", TransactionID: "t3"},
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(NewStreamHandler(repo, hub, nil).StreamEvents))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?user_id=u1", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	s := bufio.NewScanner(resp.Body)
+	assert.Equal(t, []string{"3"}, readEventIDs(t, s, 1))
+
+	// already replayed, of another user, then a new one
+	hub.Publish(domain.TransactionEvent{Seq: 3, UserID: "u1", TransactionID: "t3"})
+	hub.Publish(domain.TransactionEvent{Seq: 6, UserID: "u2", TransactionID: "t6"})
+	hub.Publish(domain.TransactionEvent{Seq: 5, UserID: "u1", TransactionID: "t5"})
+	assert.Equal(t, []string{"5"}, readEventIDs(t, s, 1))
+
+	// seq 4 was taken by a transaction that committed after the one of seq 5
+	hub.Publish(domain.TransactionEvent{Seq: 4, UserID: "u1", TransactionID: "t4"})
+	assert.Equal(t, []string{"4"}, readEventIDs(t, s, 1))
+}
+
+func TestStreamEvents_InvalidLastEventID(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/transactions/stream", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	newTestRouter().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
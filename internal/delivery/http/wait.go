@@ -0,0 +1,56 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
+	"context"
+	"net/http"
+	"time"
+)
+
+// waitBuffer is the number of events a waiting request can hold; it only expects one.
+const waitBuffer = 4
+
+// parseWait returns the ?wait duration, such as 10s, capped at max. Zero means not to wait.
+func parseWait(r *http.Request, max time.Duration) (time.Duration, error) {
+	v := r.URL.Query().Get("wait")
+	if v == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		validation := &domain.ValidationError{}
+		validation.Add("wait", "must be a non-negative duration such as 10s")
+		return 0, validation.Err()
+	}
+
+	return min(d, max), nil
+}
+
+// awaitResult waits up to wait for the processing result of trans on sub and applies it to trans.
+// If the wait is over first, trans is left pending.
+func awaitResult(ctx context.Context, sub *events.Subscription, trans *domain.Transaction, wait time.Duration) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if ev.Status == domain.StatusPending {
+				continue
+			}
+			trans.Done = ev.Done
+			trans.ProcessedAt = ev.ProcessedAt
+			trans.Status = ev.Status
+			return
+		case <-timer.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateTransaction_WaitForResult(t *testing.T) {
+	hub := events.NewHub()
+	processedAt := time.Now()
+	publisher := &fakePublisher{onSend: func(trans *domain.Transaction) {
+		// the consumer stores the result shortly after the message is published
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			hub.Publish(domain.TransactionEvent{TransactionID: trans.ID, Status: domain.StatusSucceeded, Done: true, ProcessedAt: &processedAt})
+		}()
+	}}
+	router := newTestRouterFrom(testDeps{publisher: publisher, hub: hub})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/transaction?wait=10s",
+		strings.NewReader(`{"user_id": "u1", "amount": 10, "currency": "USD"}`)))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var trans domain.Transaction
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &trans))
+	assert.Equal(t, domain.StatusSucceeded, trans.Status)
+	assert.True(t, trans.Done)
+	assert.NotNil(t, trans.ProcessedAt)
+}
+
+func TestGetTransaction_WaitMatchesStoredID(t *testing.T) {
+	hub := events.NewHub()
+	repo := &fakeRepo{transactions: map[string]*domain.Transaction{
+		"id-1": {ID: "id-1", Status: domain.StatusPending},
+	}}
+	router := newTestRouterFrom(testDeps{repo: repo, hub: hub})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		hub.Publish(domain.TransactionEvent{TransactionID: "id-1", Status: domain.StatusSucceeded, Done: true})
+	}()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/transactions/ID-1?wait=1s", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var trans domain.Transaction
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &trans))
+	assert.Equal(t, domain.StatusSucceeded, trans.Status)
+}
+
+func TestGetTransaction_WaitTimesOut(t *testing.T) {
+	repo := &fakeRepo{transactions: map[string]*domain.Transaction{
+		"id-1": {ID: "id-1", Status: domain.StatusPending},
+	}}
+	router := newTestRouterFrom(testDeps{repo: repo})
+
+	// the router caps the wait at one second
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/transactions/id-1?wait=1m", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	var trans domain.Transaction
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &trans))
+	assert.Equal(t, domain.StatusPending, trans.Status)
+}
+
+func TestGetTransaction_NoWaitWhenProcessed(t *testing.T) {
+	repo := &fakeRepo{transactions: map[string]*domain.Transaction{
+		"id-1": {ID: "id-1", Status: domain.StatusFailed},
+	}}
+	router := newTestRouterFrom(testDeps{repo: repo})
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/transactions/id-1?wait=1s", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestWait_Invalid(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/transactions/id-1?wait=soon", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
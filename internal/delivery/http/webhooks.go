@@ -0,0 +1,170 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, wh *domain.Webhook) error
+	ListWebhooks(ctx context.Context) ([]*domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	ListDeliveries(ctx context.Context, webhookID string, status domain.DeliveryStatus, limit int) ([]*domain.WebhookDelivery, error)
+	Redeliver(ctx context.Context, webhookID, deliveryID string) (*domain.WebhookDelivery, error)
+}
+
+const (
+	minWebhookSecretLength = 16
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+type WebhookHandler struct {
+	repo         WebhookRepository
+	maxBodyBytes int64
+}
+
+func NewWebhookHandler(repo WebhookRepository, maxBodyBytes int64) *WebhookHandler {
+	return &WebhookHandler{
+		repo:         repo,
+		maxBodyBytes: maxBodyBytes,
+	}
+}
+
+// createWebhookRequest is the body of POST /webhooks.
+type createWebhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+}
+
+// toWebhook converts req to a new webhook, adding every problem found to errs.
+// Omitted event types subscribe the webhook to all of them.
+func (req *createWebhookRequest) toWebhook(errs *domain.ValidationError) *domain.Webhook {
+	u, err := url.Parse(req.URL)
+	switch {
+	case req.URL == "":
+		errs.Add("url", "is required")
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		errs.Add("url", "must be an absolute http or https URL")
+	}
+
+	if len(req.Secret) < minWebhookSecretLength {
+		errs.Add("secret", fmt.Sprintf("must be at least %d characters", minWebhookSecretLength))
+	}
+
+	wh := &domain.Webhook{URL: req.URL, Secret: req.Secret}
+	if len(req.EventTypes) == 0 {
+		wh.EventTypes = slices.Clone(domain.WebhookEventTypes)
+	}
+	for i, eventType := range req.EventTypes {
+		if !slices.Contains(domain.WebhookEventTypes, eventType) {
+			errs.Add(fmt.Sprintf("event_types[%d]", i), "must be one of "+strings.Join(domain.WebhookEventTypes, ", "))
+			continue
+		}
+		if !slices.Contains(wh.EventTypes, eventType) {
+			wh.EventTypes = append(wh.EventTypes, eventType)
+		}
+	}
+
+	return wh
+}
+
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	req := &createWebhookRequest{}
+	if err := decodeJSON(w, r, h.maxBodyBytes, req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	validation := &domain.ValidationError{}
+	wh := req.toWebhook(validation)
+	if err := validation.Err(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.repo.CreateWebhook(r.Context(), wh); err != nil {
+		writeError(w, r, fmt.Errorf("failed to create webhook: %w", err))
+		return
+	}
+
+	w.Header().Set("Location", webhookURL(wh.ID))
+	writeJSON(w, r, http.StatusCreated, wh)
+}
+
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.repo.ListWebhooks(r.Context())
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to list webhooks: %w", err))
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, webhooks)
+}
+
+// DeleteWebhook unsubscribes a webhook; its pending deliveries are dropped.
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.repo.DeleteWebhook(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, r, fmt.Errorf("failed to delete webhook: %w", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries returns the latest deliveries of a webhook, newest first,
+// optionally filtered by ?status and bounded by ?limit.
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	var (
+		query      = r.URL.Query()
+		validation = &domain.ValidationError{}
+		status     = domain.DeliveryStatus(query.Get("status"))
+		limit      = defaultDeliveriesLimit
+		err        error
+	)
+
+	switch status {
+	case "", domain.DeliveryPending, domain.DeliverySucceeded, domain.DeliveryFailed:
+	default:
+		validation.Add("status", "must be pending, succeeded or failed")
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxDeliveriesLimit {
+			validation.Add("limit", fmt.Sprintf("must be an integer from 1 to %d", maxDeliveriesLimit))
+		}
+	}
+	if err = validation.Err(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	deliveries, err := h.repo.ListDeliveries(r.Context(), r.PathValue("id"), status, limit)
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to list webhook deliveries: %w", err))
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, deliveries)
+}
+
+// Redeliver queues a new delivery with the payload of an earlier one, whatever its outcome.
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	d, err := h.repo.Redeliver(r.Context(), r.PathValue("id"), r.PathValue("delivery_id"))
+	if err != nil {
+		writeError(w, r, fmt.Errorf("failed to redeliver webhook: %w", err))
+		return
+	}
+
+	writeJSON(w, r, http.StatusAccepted, d)
+}
+
+func webhookURL(id string) string {
+	return apiPrefix + "/webhooks/" + url.PathEscape(id)
+}
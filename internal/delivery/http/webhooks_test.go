@@ -0,0 +1,183 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeWebhookRepo struct {
+	webhooks   []*domain.Webhook
+	deliveries []*domain.WebhookDelivery
+}
+
+func newFakeWebhookRepo() *fakeWebhookRepo {
+	return &fakeWebhookRepo{}
+}
+
+func (f *fakeWebhookRepo) find(id string) *domain.Webhook {
+	for _, wh := range f.webhooks {
+		if wh.ID == id {
+			return wh
+		}
+	}
+	return nil
+}
+
+func (f *fakeWebhookRepo) CreateWebhook(ctx context.Context, wh *domain.Webhook) error {
+	wh.ID = fmt.Sprintf("wh-%d", len(f.webhooks)+1)
+	f.webhooks = append(f.webhooks, wh)
+	return nil
+}
+
+func (f *fakeWebhookRepo) ListWebhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	return f.webhooks, nil
+}
+
+func (f *fakeWebhookRepo) DeleteWebhook(ctx context.Context, id string) error {
+	for i, wh := range f.webhooks {
+		if wh.ID == id {
+			f.webhooks = append(f.webhooks[:i], f.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (f *fakeWebhookRepo) ListDeliveries(ctx context.Context, webhookID string, status domain.DeliveryStatus, limit int) ([]*domain.WebhookDelivery, error) {
+	if f.find(webhookID) == nil {
+		return nil, domain.ErrNotFound
+	}
+	deliveries := []*domain.WebhookDelivery{}
+	for _, d := range f.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) && len(deliveries) < limit {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+func (f *fakeWebhookRepo) Redeliver(ctx context.Context, webhookID, deliveryID string) (*domain.WebhookDelivery, error) {
+	for _, d := range f.deliveries {
+		if d.ID == deliveryID && d.WebhookID == webhookID {
+			copied := &domain.WebhookDelivery{
+				ID:           fmt.Sprintf("d-%d", len(f.deliveries)+1),
+				WebhookID:    webhookID,
+				EventType:    d.EventType,
+				Payload:      d.Payload,
+				Status:       domain.DeliveryPending,
+				RedeliveryOf: d.ID,
+			}
+			f.deliveries = append(f.deliveries, copied)
+			return copied, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func TestCreateWebhook(t *testing.T) {
+	repo := newFakeWebhookRepo()
+	router := newTestRouterFrom(testDeps{webhooks: repo})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/webhooks",
+		strings.NewReader(`{"url":"https://example.com/hook","secret":"0123456789abcdef"}`)))
+
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, "/v1/webhooks/wh-1", rec.Header().Get("Location"))
+	assert.NotContains(t, rec.Body.String(), "0123456789abcdef")
+
+	var wh domain.Webhook
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &wh))
+	assert.Equal(t, domain.WebhookEventTypes, wh.EventTypes)
+	assert.Equal(t, "0123456789abcdef", repo.webhooks[0].Secret)
+}
+
+func TestCreateWebhook_Invalid(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/webhooks",
+		strings.NewReader(`{"url":"ftp://example.com","secret":"short","event_types":["transaction.failed","transaction.created"]}`)))
+
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	var body struct {
+		Error struct {
+			Details []domain.FieldError `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	fields := map[string]bool{}
+	for _, f := range body.Error.Details {
+		fields[f.Field] = true
+	}
+	assert.Equal(t, map[string]bool{"url": true, "secret": true, "event_types[1]": true}, fields)
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	repo := newFakeWebhookRepo()
+	repo.webhooks = []*domain.Webhook{{ID: "wh-1"}}
+	router := newTestRouterFrom(testDeps{webhooks: repo})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/webhooks/wh-1", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, repo.webhooks)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/webhooks/wh-1", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestListDeliveries(t *testing.T) {
+	repo := newFakeWebhookRepo()
+	repo.webhooks = []*domain.Webhook{{ID: "wh-1"}}
+	repo.deliveries = []*domain.WebhookDelivery{
+		{ID: "d-1", WebhookID: "wh-1", Status: domain.DeliveryFailed},
+		{ID: "d-2", WebhookID: "wh-1", Status: domain.DeliverySucceeded},
+	}
+	router := newTestRouterFrom(testDeps{webhooks: repo})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/webhooks/wh-1/deliveries?status=failed", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var deliveries []domain.WebhookDelivery
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &deliveries))
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "d-1", deliveries[0].ID)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/webhooks/wh-1/deliveries?status=lost&limit=0", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/webhooks/wh-2/deliveries", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRedeliver(t *testing.T) {
+	repo := newFakeWebhookRepo()
+	repo.webhooks = []*domain.Webhook{{ID: "wh-1"}}
+	repo.deliveries = []*domain.WebhookDelivery{{ID: "d-1", WebhookID: "wh-1", Status: domain.DeliveryFailed}}
+	router := newTestRouterFrom(testDeps{webhooks: repo})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/webhooks/wh-1/deliveries/d-1/redeliver", nil))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	var d domain.WebhookDelivery
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &d))
+	assert.Equal(t, domain.DeliveryPending, d.Status)
+	assert.Equal(t, "d-1", d.RedeliveryOf)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/webhooks/wh-2/deliveries/d-1/redeliver", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
+	"TransactiStream/internal/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long a client may stay silent, including pongs, before it is disconnected.
+	wsPongWait         = 60 * time.Second
+	wsPingInterval     = wsPongWait * 9 / 10
+	wsMaxMessageBytes  = 64 * 1024
+	wsMaxSubscriptions = 1000
+)
+
+// Message types of the WebSocket API.
+const (
+	wsSubscribe    = "subscribe"
+	wsUnsubscribe  = "unsubscribe"
+	wsSubscribed   = "subscribed"
+	wsStatus       = "status"
+	wsError        = "error"
+	wsUnknownInput = "message must be a JSON object with type subscribe or unsubscribe"
+)
+
+type wsRequest struct {
+	Type           string   `json:"type"`
+	UserIDs        []string `json:"user_ids"`
+	TransactionIDs []string `json:"transaction_ids"`
+}
+
+type wsMessage struct {
+	Type          string                   `json:"type"`
+	Subscriptions *wsSubscriptions         `json:"subscriptions,omitempty"`
+	Event         *domain.TransactionEvent `json:"event,omitempty"`
+	Error         string                   `json:"error,omitempty"`
+}
+
+type wsSubscriptions struct {
+	UserIDs        []string `json:"user_ids"`
+	TransactionIDs []string `json:"transaction_ids"`
+}
+
+// wsInbound is a client message as read by the reader goroutine.
+type wsInbound struct {
+	req wsRequest
+	err error
+}
+
+// newUpgrader accepts same-origin requests, requests without Origin (non-browser clients)
+// and requests from allowedOrigins, where "*" allows any origin.
+func newUpgrader(allowedOrigins []string) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+			if slices.Contains(allowedOrigins, "*") || slices.Contains(allowedOrigins, origin) {
+				return true
+			}
+			u, err := url.Parse(origin)
+			return err == nil && u.Host == r.Host
+		},
+	}
+}
+
+// Subscribe upgrades to a WebSocket on which the client subscribes to and unsubscribes from
+// user and transaction IDs and receives their status changes. Every change of the subscriptions
+// is answered with the full current set. The server pings the client to detect dead connections.
+func (h *StreamHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already responded
+		logger.FromContext(ctx).Debug().Err(err).Msg("WebSocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	inbound := make(chan wsInbound)
+	done := make(chan struct{})
+	defer close(done)
+	go readRequests(conn, inbound, done)
+
+	var (
+		users        = map[string]bool{}
+		transactions = map[string]bool{}
+		sub          *events.Subscription
+		evC          <-chan domain.TransactionEvent
+	)
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	write := func(msg wsMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(msg)
+	}
+
+	for {
+		var err error
+
+		select {
+		case in, ok := <-inbound:
+			if !ok {
+				return
+			}
+			if in.err != nil {
+				err = write(wsMessage{Type: wsError, Error: in.err.Error()})
+				break
+			}
+
+			if msg := applyRequest(in.req, users, transactions); msg != "" {
+				err = write(wsMessage{Type: wsError, Error: msg})
+				break
+			}
+
+			// an empty filter matches every event, so nothing is subscribed until the client asks for something;
+			// a changed filter is swapped in place so that no event is missed in between
+			filter := domain.EventFilter{UserIDs: sortedKeys(users), TransactionIDs: sortedKeys(transactions)}
+			switch {
+			case filter.Empty():
+				if sub != nil {
+					sub.Close()
+					sub, evC = nil, nil
+				}
+			case sub != nil:
+				sub.SetFilter(filter)
+			default:
+				sub = h.hub.Subscribe(filter, streamBuffer)
+				evC = sub.C
+			}
+			err = write(wsMessage{Type: wsSubscribed, Subscriptions: &wsSubscriptions{
+				UserIDs:        filter.UserIDs,
+				TransactionIDs: filter.TransactionIDs,
+			}})
+		case ev, ok := <-evC:
+			if !ok {
+				logger.FromContext(ctx).Warn().Msg("WebSocket client fell behind, closing it")
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"),
+					time.Now().Add(wsWriteWait))
+				return
+			}
+			err = write(wsMessage{Type: wsStatus, Event: &ev})
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+		}
+
+		if err != nil {
+			logger.FromContext(ctx).Debug().Err(err).Msg("WebSocket closed")
+			return
+		}
+	}
+}
+
+// readRequests passes client messages to inbound until the connection fails, then closes inbound.
+func readRequests(conn *websocket.Conn, inbound chan<- wsInbound, done <-chan struct{}) {
+	defer close(inbound)
+
+	conn.SetReadLimit(wsMaxMessageBytes)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		in := wsInbound{}
+		if err = json.Unmarshal(data, &in.req); err != nil {
+			in.err = errors.New(wsUnknownInput)
+		}
+
+		select {
+		case inbound <- in:
+		case <-done:
+			return
+		}
+	}
+}
+
+// applyRequest updates the subscription sets and returns a message for the client if req is invalid.
+func applyRequest(req wsRequest, users, transactions map[string]bool) string {
+	switch req.Type {
+	case wsSubscribe:
+		if len(users)+len(transactions)+len(req.UserIDs)+len(req.TransactionIDs) > wsMaxSubscriptions {
+			return fmt.Sprintf("at most %d subscriptions are allowed per connection", wsMaxSubscriptions)
+		}
+		for _, id := range req.UserIDs {
+			users[id] = true
+		}
+		for _, id := range req.TransactionIDs {
+			transactions[id] = true
+		}
+	case wsUnsubscribe:
+		for _, id := range req.UserIDs {
+			delete(users, id)
+		}
+		for _, id := range req.TransactionIDs {
+			delete(transactions, id)
+		}
+	default:
+		return wsUnknownInput
+	}
+	return ""
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
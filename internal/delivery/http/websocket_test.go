@@ -0,0 +1,106 @@
+package http
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/events"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newWebSocketServer(hub *events.Hub, allowedOrigins []string) *httptest.Server {
+	mux := http.NewServeMux()
+	handle(mux, "GET /ws", http.HandlerFunc(NewStreamHandler(&fakeEventRepo{}, hub, allowedOrigins).Subscribe))
+	return httptest.NewServer(Chain(mux, RequestID, AccessLog, Recoverer))
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+
+	var msg wsMessage
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	assert.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestSubscribe_Updates(t *testing.T) {
+	hub := events.NewHub()
+	srv := newWebSocketServer(hub, nil)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, conn.WriteJSON(wsRequest{Type: wsSubscribe, UserIDs: []string{"u1"}, TransactionIDs: []string{"t9"}}))
+	assert.Equal(t, wsMessage{Type: wsSubscribed, Subscriptions: &wsSubscriptions{
+		UserIDs:        []string{"u1"},
+		TransactionIDs: []string{"t9"},
+	}}, readMessage(t, conn))
+
+	hub.Publish(domain.TransactionEvent{Seq: 1, UserID: "u2", TransactionID: "t1"})
+	hub.Publish(domain.TransactionEvent{Seq: 2, UserID: "u1", TransactionID: "t2", Status: domain.StatusSucceeded})
+
+	msg := readMessage(t, conn)
+	assert.Equal(t, wsStatus, msg.Type)
+	assert.Equal(t, int64(2), msg.Event.Seq)
+	assert.Equal(t, domain.StatusSucceeded, msg.Event.Status)
+
+	assert.NoError(t, conn.WriteJSON(wsRequest{Type: wsUnsubscribe, UserIDs: []string{"u1"}}))
+	assert.Equal(t, []string{}, readMessage(t, conn).Subscriptions.UserIDs)
+
+	hub.Publish(domain.TransactionEvent{Seq: 3, UserID: "u1", TransactionID: "t3"})
+	hub.Publish(domain.TransactionEvent{Seq: 4, UserID: "u5", TransactionID: "t9"})
+	assert.Equal(t, int64(4), readMessage(t, conn).Event.Seq)
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	assert.Equal(t, wsMessage{Type: wsError, Error: wsUnknownInput}, readMessage(t, conn))
+}
+
+func TestSubscribe_ChangeKeepsPendingEvents(t *testing.T) {
+	hub := events.NewHub()
+	srv := newWebSocketServer(hub, nil)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, conn.WriteJSON(wsRequest{Type: wsSubscribe, UserIDs: []string{"u1"}}))
+	assert.Equal(t, wsSubscribed, readMessage(t, conn).Type)
+
+	// the event may still be queued on the subscription when the filter changes
+	hub.Publish(domain.TransactionEvent{Seq: 1, UserID: "u1", TransactionID: "t1"})
+	assert.NoError(t, conn.WriteJSON(wsRequest{Type: wsSubscribe, UserIDs: []string{"u2"}}))
+
+	var types []string
+	for _, msg := range []wsMessage{readMessage(t, conn), readMessage(t, conn)} {
+		types = append(types, msg.Type)
+		if msg.Type == wsStatus {
+			assert.Equal(t, int64(1), msg.Event.Seq)
+		}
+	}
+	assert.ElementsMatch(t, []string{wsStatus, wsSubscribed}, types)
+
+	hub.Publish(domain.TransactionEvent{Seq: 2, UserID: "u2", TransactionID: "t2"})
+	assert.Equal(t, int64(2), readMessage(t, conn).Event.Seq)
+}
+
+func TestSubscribe_Origin(t *testing.T) {
+	srv := newWebSocketServer(events.NewHub(), []string{"https://dashboard.example.com"})
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example.com"}})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://dashboard.example.com"}})
+	assert.NoError(t, err)
+	conn.Close()
+}
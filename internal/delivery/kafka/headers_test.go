@@ -0,0 +1,30 @@
+package kafkaService
+
+import (
+	"context"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestHeaderCarrier_RoundTrip(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	msg := kafka.Message{Headers: []kafka.Header{{Key: "other", Value: []byte("x")}}}
+	propagation.TraceContext{}.Inject(ctx, headerCarrier{&msg.Headers})
+
+	assert.Len(t, msg.Headers, 2)
+	assert.ElementsMatch(t, []string{"other", "traceparent"}, headerCarrier{&msg.Headers}.Keys())
+
+	extracted := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), headerCarrier{&msg.Headers}))
+	assert.Equal(t, sc.TraceID(), extracted.TraceID())
+	assert.Equal(t, sc.SpanID(), extracted.SpanID())
+	assert.True(t, extracted.IsRemote())
+}
@@ -3,21 +3,34 @@ package kafkaService
 import (
 	"TransactiStream/internal/domain"
 	"TransactiStream/internal/logger"
+	"TransactiStream/internal/metrics"
+	"TransactiStream/internal/tracing"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
 type Repository interface {
 	Update(ctx context.Context, trans *domain.Transaction) error
-	SetProcessedAt(ctx context.Context, id string) error
+	SetProcessedAt(ctx context.Context, id string) (*domain.TransactionEvent, error)
 }
 
 type KafkaService struct {
-	writer *kafka.Writer
-	reader *kafka.Reader
-	repo   Repository
+	brokers []string
+	writer  *kafka.Writer
+	reader  *kafka.Reader
+	repo    Repository
+	running atomic.Bool
 }
 
 func NewKafka(brokers []string, writeTopic, readTopic, groupID string, repo Repository) *KafkaService {
@@ -36,63 +49,233 @@ func NewKafka(brokers []string, writeTopic, readTopic, groupID string, repo Repo
 	})
 
 	return &KafkaService{
-		writer: writer,
-		reader: reader,
-		repo:   repo,
+		brokers: brokers,
+		writer:  writer,
+		reader:  reader,
+		repo:    repo,
 	}
 }
 
-func (k *KafkaService) SendMessage(ctx context.Context, trans *domain.Transaction) error {
-	message, err := json.Marshal(trans)
+func (k *KafkaService) SendMessage(ctx context.Context, trans *domain.Transaction) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, k.writer.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(k.writer.Topic),
+			semconv.MessagingKafkaMessageKey(trans.ID),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	msg, err := newMessage(ctx, trans)
 	if err != nil {
-		return fmt.Errorf("failed to marshal transaction: %w", err)
+		return err
 	}
 
-	err = k.writer.WriteMessages(ctx,
-		kafka.Message{
-			Key:   []byte(trans.ID),
-			Value: message,
-		},
-	)
+	start := time.Now()
+	err = k.writer.WriteMessages(ctx, msg)
+	metrics.KafkaPublishDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
-		return fmt.Errorf("failed to write message: %w", err)
+		metrics.KafkaPublishErrors.Inc()
+		return fmt.Errorf("%w: failed to write message: %w", domain.ErrUnavailable, err)
 	}
 
-	logger.Infof("Message sent successfully: %s", trans.ID)
+	logger.FromContext(ctx).Info().Str("transaction_id", trans.ID).Msg("Message sent successfully")
 	return nil
 }
 
+// SendMessages publishes transactions in a single write and returns per-transaction errors
+// aligned with the input, or nil if every message was written.
+func (k *KafkaService) SendMessages(ctx context.Context, transactions []*domain.Transaction) []error {
+	ctx, span := tracing.Tracer().Start(ctx, k.writer.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(k.writer.Topic),
+			semconv.MessagingBatchMessageCount(len(transactions)),
+		),
+	)
+	defer span.End()
+
+	var (
+		errs     = make([]error, len(transactions))
+		failed   int
+		msgs     = make([]kafka.Message, 0, len(transactions))
+		msgIndex = make([]int, 0, len(transactions))
+	)
+
+	for i, trans := range transactions {
+		msg, err := newMessage(ctx, trans)
+		if err != nil {
+			errs[i] = err
+			failed++
+			continue
+		}
+		msgs = append(msgs, msg)
+		msgIndex = append(msgIndex, i)
+	}
+
+	start := time.Now()
+	err := k.writer.WriteMessages(ctx, msgs...)
+	metrics.KafkaPublishDuration.Observe(time.Since(start).Seconds())
+
+	var writeErrs kafka.WriteErrors
+	switch {
+	case errors.As(err, &writeErrs):
+		for j, werr := range writeErrs {
+			if werr != nil {
+				errs[msgIndex[j]] = fmt.Errorf("%w: failed to write message: %w", domain.ErrUnavailable, werr)
+				failed++
+			}
+		}
+	case err != nil:
+		for _, i := range msgIndex {
+			errs[i] = fmt.Errorf("%w: failed to write messages: %w", domain.ErrUnavailable, err)
+		}
+		failed += len(msgIndex)
+	}
+
+	if failed == 0 {
+		logger.FromContext(ctx).Info().Int("count", len(transactions)).Msg("Messages sent successfully")
+		return nil
+	}
+
+	metrics.KafkaPublishErrors.Add(float64(failed))
+	span.SetStatus(codes.Error, fmt.Sprintf("%d of %d messages failed", failed, len(transactions)))
+	logger.FromContext(ctx).Error().Int("failed", failed).Int("count", len(transactions)).Msg("Failed to send some messages")
+
+	return errs
+}
+
+// newMessage encodes trans with the trace context and request ID of ctx in its headers.
+func newMessage(ctx context.Context, trans *domain.Transaction) (kafka.Message, error) {
+	value, err := json.Marshal(trans)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("failed to marshal transaction: %w", err)
+	}
+
+	msg := kafka.Message{
+		Key:   []byte(trans.ID),
+		Value: value,
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{&msg.Headers})
+	if id := logger.RequestID(ctx); id != "" {
+		headerCarrier{&msg.Headers}.Set(requestIDHeader, id)
+	}
+
+	return msg, nil
+}
+
 func (k *KafkaService) ReceiveMessages(ctx context.Context) error {
+	k.running.Store(true)
+	defer k.running.Store(false)
+
 	for {
 		m, err := k.reader.ReadMessage(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to read message: %w", err)
 		}
 
-		var trans domain.Transaction
-		err = json.Unmarshal(m.Value, &trans)
-		if err != nil {
-			logger.Errorf("failed to unmarshal message: %v", err)
-			continue
-		}
+		result := k.handleMessage(ctx, m)
+		metrics.ObserveConsumed(m.Topic, m.Partition, m.Offset, m.HighWaterMark, result)
+	}
+}
 
-		logger.Infof("Received transaction: %+v", trans)
+// handleMessage stores one processed transaction and returns the result label for metrics.
+func (k *KafkaService) handleMessage(ctx context.Context, m kafka.Message) string {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier{&m.Headers})
+	ctx, span := tracing.Tracer().Start(ctx, m.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(m.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(m.Partition)),
+			semconv.MessagingKafkaMessageOffset(int(m.Offset)),
+			semconv.MessagingKafkaMessageKey(string(m.Key)),
+		),
+	)
+	defer span.End()
 
-		if err := k.repo.Update(ctx, &trans); err != nil {
-			logger.Errorf("failed to update transaction in repository: %v", err)
-			continue
-		}
+	ctx = logger.WithKafkaMessage(ctx, m.Topic, m.Partition, m.Offset)
+	if id := (headerCarrier{&m.Headers}).Get(requestIDHeader); id != "" {
+		ctx = logger.WithRequestID(ctx, id)
+	}
 
-		if err := k.repo.SetProcessedAt(ctx, trans.ID); err != nil {
-			logger.Errorf("failed to set processed at time: %v", err)
-			continue
-		}
+	var trans domain.Transaction
+	if err := json.Unmarshal(m.Value, &trans); err != nil {
+		logger.FromContext(ctx).Error().Err(err).Msg("failed to unmarshal message")
+		span.SetStatus(codes.Error, err.Error())
+		return "invalid"
+	}
+
+	ctx = logger.WithTransactionID(ctx, trans.ID)
+	logger.FromContext(ctx).Info().Object("transaction", &trans).Msg("Received transaction")
+
+	if err := k.repo.Update(ctx, &trans); err != nil {
+		logger.FromContext(ctx).Error().Err(err).Msg("failed to update transaction in repository")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "error"
+	}
 
+	if _, err := k.repo.SetProcessedAt(ctx, trans.ID); err != nil {
+		logger.FromContext(ctx).Error().Err(err).Msg("failed to set processed at time")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "error"
 	}
+
+	if !trans.Timestamp.IsZero() {
+		metrics.TransactionProcessing.WithLabelValues(strconv.FormatBool(trans.Done)).
+			Observe(time.Since(trans.Timestamp).Seconds())
+	}
+
+	return "ok"
+}
+
+// Close stops the producer and the consumer.
+func (k *KafkaService) Close() error {
+	return errors.Join(k.writer.Close(), k.reader.Close())
+}
+
+// Running reports whether ReceiveMessages is currently consuming.
+func (k *KafkaService) Running() bool {
+	return k.running.Load()
+}
+
+// Ping checks that a broker is reachable and that both topics exist.
+func (k *KafkaService) Ping(ctx context.Context) error {
+	conn, err := dialAny(ctx, k.brokers)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	for _, topic := range []string{k.writer.Topic, k.reader.Config().Topic} {
+		partitions, err := conn.ReadPartitions(topic)
+		if err != nil {
+			return fmt.Errorf("failed to read partitions of %s: %w", topic, err)
+		}
+		if len(partitions) == 0 {
+			return fmt.Errorf("topic %s has no partitions", topic)
+		}
+	}
+
+	return nil
 }
 
 func CreateTopic(brokers []string, topic string) error {
-	conn, err := kafka.Dial("tcp", brokers[0])
+	conn, err := dialAny(context.Background(), brokers)
 	if err != nil {
 		return err
 	}
@@ -104,7 +287,7 @@ func CreateTopic(brokers []string, topic string) error {
 	}
 
 	var connController *kafka.Conn
-	connController, err = kafka.Dial("tcp", fmt.Sprintf("%s:%d", controller.Host, controller.Port))
+	connController, err = kafka.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
 	if err != nil {
 		return err
 	}
@@ -125,3 +308,21 @@ func CreateTopic(brokers []string, topic string) error {
 
 	return nil
 }
+
+// dialAny connects to the first reachable broker.
+func dialAny(ctx context.Context, brokers []string) (*kafka.Conn, error) {
+	var (
+		dialer kafka.Dialer
+		errs   []error
+	)
+
+	for _, broker := range brokers {
+		conn, err := dialer.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return nil, fmt.Errorf("failed to dial any broker: %w", errors.Join(errs...))
+}
@@ -1,20 +1,98 @@
 package domain
 
-import "time"
+import (
+	"TransactiStream/internal/logger"
+	"github.com/rs/zerolog"
+	"time"
+)
+
+type Status string
+
+const (
+	// StatusPending means the transaction was stored and has no processing result yet.
+	StatusPending   Status = "pending"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
 
 type Transaction struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Amount    float64   `json:"amount"`
-	Currency  string    `json:"currency"`
-	Done      bool      `json:"done"`
-	Timestamp time.Time `json:"timestamp"`
-}
-
-type Statistics struct {
-	TotalTransactions     int      `json:"total_transactions"`
-	FailedTransactions    int      `json:"failed_transactions"`
-	TotalUsers            int      `json:"total_users"`
-	AverageProcessingTime float64  `json:"average_processing_time"`
-	Currencies            []string `json:"currencies"`
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Amount      float64    `json:"amount"`
+	Currency    string     `json:"currency"`
+	Done        bool       `json:"done"`
+	Status      Status     `json:"status,omitempty"`
+	Timestamp   time.Time  `json:"timestamp"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
+
+// MarshalZerologObject logs the fields of the transaction allowed by the PII policy.
+func (t *Transaction) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("id", t.ID).
+		Interface("user_id", logger.PII("user_id", t.UserID)).
+		Interface("amount", logger.PII("amount", t.Amount)).
+		Str("currency", t.Currency).
+		Bool("done", t.Done).
+		Time("timestamp", t.Timestamp)
+}
+
+// StatusOf derives the status from the processing result and the time it was stored.
+func StatusOf(done bool, processedAt *time.Time) Status {
+	switch {
+	case processedAt == nil:
+		return StatusPending
+	case done:
+		return StatusSucceeded
+	default:
+		return StatusFailed
+	}
+}
+
+// TransactionFilter narrows listings and exports; zero fields match everything.
+type TransactionFilter struct {
+	UserID   string
+	Currency string
+	Status   Status
+	// From and To bound the creation time; From is inclusive and To exclusive.
+	From time.Time
+	To   time.Time
+}
+
+// TransactionEvent records that the consumer stored the processing result of a transaction.
+// Seq orders all events and lets subscribers resume after the last one they saw.
+type TransactionEvent struct {
+	Seq           int64      `json:"seq"`
+	TransactionID string     `json:"transaction_id"`
+	UserID        string     `json:"user_id"`
+	Status        Status     `json:"status"`
+	Done          bool       `json:"done"`
+	ProcessedAt   *time.Time `json:"processed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// EventFilter selects events by user or transaction; an empty filter selects every event.
+type EventFilter struct {
+	UserIDs        []string
+	TransactionIDs []string
+}
+
+func (f EventFilter) Empty() bool {
+	return len(f.UserIDs) == 0 && len(f.TransactionIDs) == 0
+}
+
+func (f EventFilter) Match(ev TransactionEvent) bool {
+	if f.Empty() {
+		return true
+	}
+	for _, id := range f.UserIDs {
+		if id == ev.UserID {
+			return true
+		}
+	}
+	for _, id := range f.TransactionIDs {
+		if id == ev.TransactionID {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"TransactiStream/internal/logger"
+	"bytes"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTransaction_MarshalZerologObject(t *testing.T) {
+	trans := &Transaction{
+		ID:        "tx-1",
+		UserID:    "user-1",
+		Amount:    12.5,
+		Currency:  "USD",
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	log := zerolog.New(&buf)
+	log.Info().Object("transaction", trans).Send()
+
+	var line struct {
+		Transaction map[string]any `json:"transaction"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "tx-1", line.Transaction["id"])
+	assert.Equal(t, logger.Redacted, line.Transaction["user_id"])
+	assert.Equal(t, logger.Redacted, line.Transaction["amount"])
+	assert.Equal(t, "USD", line.Transaction["currency"])
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+type ImportStatus string
+
+const (
+	ImportRunning   ImportStatus = "running"
+	ImportCompleted ImportStatus = "completed"
+	// ImportFailed means the import stopped early; rows stored before the failure are kept.
+	ImportFailed ImportStatus = "failed"
+)
+
+// Import summarises loading a file of transactions.
+type Import struct {
+	ID          string       `json:"id"`
+	Format      string       `json:"format"`
+	SkipPublish bool         `json:"skip_publish"`
+	Status      ImportStatus `json:"status"`
+	// Total counts data rows read, Imported those stored and Rejected those that failed validation.
+	Total         int        `json:"total"`
+	Imported      int        `json:"imported"`
+	Rejected      int        `json:"rejected"`
+	PublishFailed int        `json:"publish_failed"`
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+// ImportError is a line of an imported file that was not stored or not published.
+type ImportError struct {
+	Line   int          `json:"line"`
+	Raw    string       `json:"raw"`
+	Errors []FieldError `json:"errors"`
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// JSONFieldError explains an error of a strict json.Decoder that points at a single field:
+// a value of the wrong type or a field that is not allowed. ok is false for other errors.
+func JSONFieldError(err error) (_ FieldError, ok bool) {
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return FieldError{Field: typeErr.Field, Message: "must be a " + jsonType(typeErr.Type)}, true
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return FieldError{Field: field, Message: "is not allowed"}, true
+	}
+	return FieldError{}, false
+}
+
+// jsonType names the JSON type expected for values of t.
+func jsonType(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return "RFC 3339 timestamp"
+	}
+
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
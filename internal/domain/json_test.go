@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestJSONFieldError(t *testing.T) {
+	type value struct {
+		Amount      float64    `json:"amount"`
+		Done        *bool      `json:"done"`
+		ProcessedAt *time.Time `json:"processed_at"`
+	}
+
+	tests := []struct {
+		input string
+		want  FieldError
+		ok    bool
+	}{
+		{`{"amount": "1"}`, FieldError{Field: "amount", Message: "must be a number"}, true},
+		{`{"done": 1}`, FieldError{Field: "done", Message: "must be a boolean"}, true},
+		{`{"note": "x"}`, FieldError{Field: "note", Message: "is not allowed"}, true},
+		{`[1]`, FieldError{}, false},
+		{`{"amount": `, FieldError{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			dec := json.NewDecoder(bytes.NewReader([]byte(tt.input)))
+			dec.DisallowUnknownFields()
+			err := dec.Decode(&value{})
+
+			got, ok := JSONFieldError(err)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
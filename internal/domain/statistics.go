@@ -0,0 +1,122 @@
+package domain
+
+import "time"
+
+type Statistics struct {
+	TotalTransactions int `json:"total_transactions"`
+	// FailedTransactions equals Statuses.Failed; it is kept for older clients.
+	FailedTransactions int          `json:"failed_transactions"`
+	Statuses           StatusCounts `json:"statuses"`
+	// SuccessRate is the share (0..1) of succeeded transactions among the terminal ones.
+	SuccessRate float64 `json:"success_rate"`
+	// TotalUsers still counts the previous user of a transaction whose user, currency or time
+	// was changed, until the statistics are rebuilt.
+	TotalUsers            int             `json:"total_users"`
+	AverageProcessingTime float64         `json:"average_processing_time"`
+	ProcessingTime        ProcessingTimes `json:"processing_time"`
+	Currencies            []string        `json:"currencies"`
+	// ByCurrency breaks the totals down per currency, in the order of Currencies.
+	ByCurrency []CurrencyStatistics `json:"by_currency"`
+	// Series is set when statistics are requested per Interval.
+	Series []StatisticsBucket `json:"series,omitempty"`
+}
+
+// CurrencyStatistics aggregates the transactions in one currency; amounts are in that currency.
+type CurrencyStatistics struct {
+	Currency      string  `json:"currency"`
+	Transactions  int     `json:"transactions"`
+	Volume        float64 `json:"volume"`
+	AverageAmount float64 `json:"average_amount"`
+	// MinAmount and MaxAmount may still include the previous amount of a transaction whose
+	// amount, currency or time was changed, until the statistics are rebuilt.
+	MinAmount float64 `json:"min_amount"`
+	MaxAmount float64 `json:"max_amount"`
+	StatusCounts
+	// SuccessRate is the share (0..1) of succeeded transactions among the terminal ones.
+	SuccessRate           float64         `json:"success_rate"`
+	AverageProcessingTime float64         `json:"average_processing_time"`
+	ProcessingTime        ProcessingTimes `json:"processing_time"`
+}
+
+// StatusCounts splits transactions by where they are in their lifecycle. Pending ones are
+// still expected to be processed; all the others are terminal.
+type StatusCounts struct {
+	Pending   int `json:"pending"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// TimedOut are published but unprocessed for longer than the pending timeout.
+	TimedOut int `json:"timed_out"`
+	// Unpublished were stored but could not be sent for processing.
+	Unpublished int `json:"unpublished"`
+}
+
+// Terminal returns the number of transactions that are not pending.
+func (c StatusCounts) Terminal() int {
+	return c.Succeeded + c.Failed + c.TimedOut + c.Unpublished
+}
+
+// SuccessRate returns the share of succeeded transactions among the terminal ones,
+// or 0 when none are terminal.
+func (c StatusCounts) SuccessRate() float64 {
+	if c.Terminal() == 0 {
+		return 0
+	}
+	return float64(c.Succeeded) / float64(c.Terminal())
+}
+
+// ProcessingTimes is the distribution of processing times in seconds, interpolated
+// between the processed transactions. All are 0 if none are processed.
+type ProcessingTimes struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+	// Estimated is set when the percentiles are estimated from a histogram of the processing
+	// times rather than computed from the transactions; Max is always exact.
+	Estimated bool `json:"estimated"`
+}
+
+// StatisticsBucket aggregates the transactions created in [Start, Start+interval).
+type StatisticsBucket struct {
+	Start        time.Time `json:"start"`
+	Transactions int       `json:"transactions"`
+	StatusCounts
+	SuccessRate           float64         `json:"success_rate"`
+	Volume                float64         `json:"volume"`
+	AverageProcessingTime float64         `json:"average_processing_time"`
+	ProcessingTime        ProcessingTimes `json:"processing_time"`
+}
+
+// Interval is the length of a statistics bucket, named as the date_trunc field it truncates to.
+type Interval string
+
+const (
+	IntervalMinute Interval = "minute"
+	IntervalHour   Interval = "hour"
+	IntervalDay    Interval = "day"
+)
+
+// Duration returns the length of i, or 0 if i is not a known interval.
+func (i Interval) Duration() time.Duration {
+	switch i {
+	case IntervalMinute:
+		return time.Minute
+	case IntervalHour:
+		return time.Hour
+	case IntervalDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// StatisticsFilter limits statistics to transactions created in [From, To), and to Currency if set;
+// zero bounds are open. A non-empty Interval also splits the window into a series of buckets.
+type StatisticsFilter struct {
+	Currency string
+	From     time.Time
+	To       time.Time
+	Interval Interval
+	// TimedOutBefore counts pending transactions created before it as timed out; zero never does.
+	TimedOutBefore time.Time
+}
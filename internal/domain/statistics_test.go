@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStatusCounts_SuccessRate(t *testing.T) {
+	assert.Zero(t, StatusCounts{Pending: 5}.SuccessRate())
+
+	c := StatusCounts{Pending: 10, Succeeded: 6, Failed: 1, TimedOut: 2, Unpublished: 1}
+	assert.Equal(t, 10, c.Terminal())
+	assert.InDelta(t, 0.6, c.SuccessRate(), 1e-9)
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Za-z]{3,10}$`)
+
+type AmountLimits struct {
+	Min float64 `yaml:"min"`
+	Max float64 `yaml:"max"`
+}
+
+type ValidationRules struct {
+	// UserIDPattern is the regular expression a user ID must match in full.
+	UserIDPattern string
+	// DefaultLimits apply to currencies without an entry in Limits.
+	DefaultLimits AmountLimits
+	// Limits are keyed by upper-case currency code.
+	Limits map[string]AmountLimits
+}
+
+// Validator checks transactions submitted by clients.
+type Validator struct {
+	userID        *regexp.Regexp
+	defaultLimits AmountLimits
+	limits        map[string]AmountLimits
+}
+
+func NewValidator(rules ValidationRules) (*Validator, error) {
+	userID, err := regexp.Compile(`^(?:` + rules.UserIDPattern + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID pattern: %w", err)
+	}
+
+	limits := make(map[string]AmountLimits, len(rules.Limits))
+	for currency, l := range rules.Limits {
+		limits[strings.ToUpper(currency)] = l
+	}
+
+	return &Validator{
+		userID:        userID,
+		defaultLimits: rules.DefaultLimits,
+		limits:        limits,
+	}, nil
+}
+
+// Validate adds a FieldError to errs for every invalid field of trans.
+// prefix is prepended to field names, e.g. "items[3]." in batches.
+func (v *Validator) Validate(trans *Transaction, prefix string, errs *ValidationError) {
+	switch {
+	case trans.UserID == "":
+		errs.Add(prefix+"user_id", "is required")
+	case !v.userID.MatchString(trans.UserID):
+		errs.Add(prefix+"user_id", "has invalid format")
+	}
+
+	switch {
+	case trans.Currency == "":
+		errs.Add(prefix+"currency", "is required")
+	case !currencyPattern.MatchString(trans.Currency):
+		errs.Add(prefix+"currency", "must be 3 to 10 latin letters")
+	}
+
+	limits := v.Limits(trans.Currency)
+	switch {
+	case math.IsNaN(trans.Amount) || math.IsInf(trans.Amount, 0):
+		errs.Add(prefix+"amount", "must be a finite number")
+	case trans.Amount <= 0:
+		errs.Add(prefix+"amount", "must be positive")
+	case trans.Amount < limits.Min:
+		errs.Add(prefix+"amount", fmt.Sprintf("must be at least %g", limits.Min))
+	case limits.Max > 0 && trans.Amount > limits.Max:
+		errs.Add(prefix+"amount", fmt.Sprintf("must be at most %g", limits.Max))
+	}
+}
+
+// Limits returns the amount limits for currency.
+func (v *Validator) Limits(currency string) AmountLimits {
+	if l, ok := v.limits[strings.ToUpper(currency)]; ok {
+		return l
+	}
+	return v.defaultLimits
+}
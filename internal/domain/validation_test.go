@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strings"
+	"testing"
+)
+
+func newTestValidator(t *testing.T) *Validator {
+	v, err := NewValidator(ValidationRules{
+		UserIDPattern: `[A-Za-z0-9_-]{1,64}`,
+		DefaultLimits: AmountLimits{Min: 0.01, Max: 1000},
+		Limits: map[string]AmountLimits{
+			"btc": {Min: 0.00000001, Max: 10},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidator_Validate(t *testing.T) {
+	v := newTestValidator(t)
+
+	cases := []struct {
+		name  string
+		trans Transaction
+		want  []FieldError
+	}{
+		{"valid", Transaction{UserID: "user_1", Amount: 10, Currency: "USD"}, nil},
+		{"valid per-currency minimum", Transaction{UserID: "u", Amount: 0.000001, Currency: "btc"}, nil},
+		{"missing fields", Transaction{}, []FieldError{
+			{"user_id", "is required"}, {"currency", "is required"}, {"amount", "must be positive"},
+		}},
+		{"negative amount", Transaction{UserID: "u", Amount: -5, Currency: "USD"}, []FieldError{
+			{"amount", "must be positive"},
+		}},
+		{"NaN amount", Transaction{UserID: "u", Amount: math.NaN(), Currency: "USD"}, []FieldError{
+			{"amount", "must be a finite number"},
+		}},
+		{"above per-currency maximum", Transaction{UserID: "u", Amount: 11, Currency: "BTC"}, []FieldError{
+			{"amount", "must be at most 10"},
+		}},
+		{"below default minimum", Transaction{UserID: "u", Amount: 0.001, Currency: "EUR"}, []FieldError{
+			{"amount", "must be at least 0.01"},
+		}},
+		{"long user id", Transaction{UserID: strings.Repeat("a", 65), Amount: 1, Currency: "USD"}, []FieldError{
+			{"user_id", "has invalid format"},
+		}},
+		{"bad currency", Transaction{UserID: "u", Amount: 1, Currency: "U$D"}, []FieldError{
+			{"currency", "must be 3 to 10 latin letters"},
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := &ValidationError{}
+			v.Validate(&tc.trans, "", errs)
+			assert.Equal(t, tc.want, errs.Fields)
+		})
+	}
+}
+
+func TestNewValidator_InvalidPattern(t *testing.T) {
+	_, err := NewValidator(ValidationRules{UserIDPattern: "("})
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types, sent when a transaction reaches a terminal state.
+const (
+	EventTransactionSucceeded = "transaction.succeeded"
+	EventTransactionFailed    = "transaction.failed"
+)
+
+// WebhookEventTypes lists every event type a webhook can subscribe to.
+var WebhookEventTypes = []string{EventTransactionSucceeded, EventTransactionFailed}
+
+// EventType returns the webhook event type of ev, or an empty string if ev is not terminal.
+func (ev TransactionEvent) EventType() string {
+	switch ev.Status {
+	case StatusSucceeded:
+		return EventTransactionSucceeded
+	case StatusFailed:
+		return EventTransactionFailed
+	}
+	return ""
+}
+
+// Webhook is a subscription of an integrator's URL to event types.
+// The secret signs deliveries and is never returned by the API.
+type Webhook struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryFailed means the delivery ran out of attempts; it can still be redelivered.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one event to be sent to one webhook, with the outcome of its last attempt.
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	EventSeq       int64           `json:"event_seq"`
+	TransactionID  string          `json:"transaction_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	RedeliveryOf   string          `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+
+	// URL and Secret are those of the webhook at the time the delivery is attempted.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookPayload is the body POSTed to a webhook.
+type WebhookPayload struct {
+	Type  string           `json:"type"`
+	Event TransactionEvent `json:"event"`
+}
@@ -0,0 +1,139 @@
+package events
+
+import (
+	"TransactiStream/internal/domain"
+	"sync"
+)
+
+// Hub fans transaction events out to in-process subscribers. Subscribers are indexed
+// by the users and transactions they follow, so publishing doesn't scan all of them.
+type Hub struct {
+	mu            sync.Mutex
+	all           map[*Subscription]struct{}
+	byUser        map[string]map[*Subscription]struct{}
+	byTransaction map[string]map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		all:           map[*Subscription]struct{}{},
+		byUser:        map[string]map[*Subscription]struct{}{},
+		byTransaction: map[string]map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription receives the events matching its filter on C. A subscriber that falls
+// more than the buffer behind is dropped and C is closed; it can catch up from the event log.
+type Subscription struct {
+	C <-chan domain.TransactionEvent
+
+	hub    *Hub
+	ch     chan domain.TransactionEvent
+	filter domain.EventFilter
+	closed bool
+}
+
+func (h *Hub) Subscribe(filter domain.EventFilter, buffer int) *Subscription {
+	ch := make(chan domain.TransactionEvent, buffer)
+	s := &Subscription{C: ch, hub: h, ch: ch, filter: filter}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.index(s)
+	return s
+}
+
+// SetFilter replaces the filter of s.
+func (s *Subscription) SetFilter(filter domain.EventFilter) {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	h.unindex(s)
+	s.filter = filter
+	h.index(s)
+}
+
+// Close unsubscribes s and closes C; it is safe to call more than once.
+func (s *Subscription) Close() {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.drop(s)
+}
+
+// Publish delivers ev to every matching subscriber without blocking.
+func (h *Hub) Publish(ev domain.TransactionEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seen := map[*Subscription]struct{}{}
+	for _, set := range []map[*Subscription]struct{}{h.all, h.byUser[ev.UserID], h.byTransaction[ev.TransactionID]} {
+		for s := range set {
+			if _, ok := seen[s]; ok {
+				continue
+			}
+			seen[s] = struct{}{}
+
+			select {
+			case s.ch <- ev:
+			default:
+				h.drop(s)
+			}
+		}
+	}
+}
+
+func (h *Hub) drop(s *Subscription) {
+	if s.closed {
+		return
+	}
+	h.unindex(s)
+	s.closed = true
+	close(s.ch)
+}
+
+func (h *Hub) index(s *Subscription) {
+	if s.filter.Empty() {
+		h.all[s] = struct{}{}
+		return
+	}
+	for _, id := range s.filter.UserIDs {
+		add(h.byUser, id, s)
+	}
+	for _, id := range s.filter.TransactionIDs {
+		add(h.byTransaction, id, s)
+	}
+}
+
+func (h *Hub) unindex(s *Subscription) {
+	delete(h.all, s)
+	for _, id := range s.filter.UserIDs {
+		remove(h.byUser, id, s)
+	}
+	for _, id := range s.filter.TransactionIDs {
+		remove(h.byTransaction, id, s)
+	}
+}
+
+func add(index map[string]map[*Subscription]struct{}, key string, s *Subscription) {
+	set, ok := index[key]
+	if !ok {
+		set = map[*Subscription]struct{}{}
+		index[key] = set
+	}
+	set[s] = struct{}{}
+}
+
+func remove(index map[string]map[*Subscription]struct{}, key string, s *Subscription) {
+	set := index[key]
+	delete(set, s)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
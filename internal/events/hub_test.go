@@ -0,0 +1,69 @@
+package events
+
+import (
+	"TransactiStream/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func receive(s *Subscription) []int64 {
+	var seqs []int64
+	for {
+		select {
+		case ev, ok := <-s.C:
+			if !ok {
+				return seqs
+			}
+			seqs = append(seqs, ev.Seq)
+		default:
+			return seqs
+		}
+	}
+}
+
+func TestHub_Filters(t *testing.T) {
+	hub := NewHub()
+
+	all := hub.Subscribe(domain.EventFilter{}, 10)
+	user := hub.Subscribe(domain.EventFilter{UserIDs: []string{"u1"}}, 10)
+	both := hub.Subscribe(domain.EventFilter{UserIDs: []string{"u1"}, TransactionIDs: []string{"t1"}}, 10)
+
+	hub.Publish(domain.TransactionEvent{Seq: 1, UserID: "u1", TransactionID: "t1"})
+	hub.Publish(domain.TransactionEvent{Seq: 2, UserID: "u2", TransactionID: "t2"})
+	hub.Publish(domain.TransactionEvent{Seq: 3, UserID: "u1", TransactionID: "t3"})
+
+	assert.Equal(t, []int64{1, 2, 3}, receive(all))
+	assert.Equal(t, []int64{1, 3}, receive(user))
+	// an event matching both the user and the transaction is delivered once
+	assert.Equal(t, []int64{1, 3}, receive(both))
+}
+
+func TestHub_SetFilterAndClose(t *testing.T) {
+	hub := NewHub()
+	s := hub.Subscribe(domain.EventFilter{TransactionIDs: []string{"t1"}}, 10)
+
+	s.SetFilter(domain.EventFilter{TransactionIDs: []string{"t2"}})
+	hub.Publish(domain.TransactionEvent{Seq: 1, TransactionID: "t1"})
+	hub.Publish(domain.TransactionEvent{Seq: 2, TransactionID: "t2"})
+	assert.Equal(t, []int64{2}, receive(s))
+
+	s.Close()
+	s.Close()
+	hub.Publish(domain.TransactionEvent{Seq: 3, TransactionID: "t2"})
+	_, ok := <-s.C
+	assert.False(t, ok)
+	assert.Empty(t, hub.byTransaction)
+}
+
+func TestHub_DropsSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	slow := hub.Subscribe(domain.EventFilter{}, 1)
+
+	hub.Publish(domain.TransactionEvent{Seq: 1})
+	hub.Publish(domain.TransactionEvent{Seq: 2})
+
+	assert.Equal(t, []int64{1}, receive(slow))
+	_, ok := <-slow.C
+	assert.False(t, ok)
+	assert.Empty(t, hub.all)
+}
@@ -0,0 +1,157 @@
+package export
+
+import (
+	"TransactiStream/internal/domain"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/parquet-go/parquet-go"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Supported export formats.
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// parquetRowGroupSize is the number of rows buffered before a Parquet row group is written.
+const parquetRowGroupSize = 64 * 1024
+
+var contentTypes = map[string]string{
+	FormatCSV:     "text/csv; charset=utf-8",
+	FormatNDJSON:  "application/x-ndjson",
+	FormatParquet: "application/vnd.apache.parquet",
+}
+
+// Encoder writes transactions to an output in one of the export formats.
+type Encoder interface {
+	Encode(trans *domain.Transaction) error
+	// Flush writes the rows encoded so far to the output, as far as the format allows.
+	Flush() error
+	// Close writes what is left, including any trailer of the format, without closing the output.
+	Close() error
+}
+
+func NewEncoder(format string, w io.Writer) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVEncoder(w)
+	case FormatNDJSON:
+		return newNDJSONEncoder(w), nil
+	case FormatParquet:
+		return newParquetEncoder(w), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// ContentType returns the media type of format, or an empty string for an unknown format.
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) (*csvEncoder, error) {
+	e := &csvEncoder{w: csv.NewWriter(w)}
+	err := e.w.Write([]string{"id", "user_id", "amount", "currency", "done", "status", "timestamp", "processed_at"})
+	return e, err
+}
+
+func (e *csvEncoder) Encode(trans *domain.Transaction) error {
+	processedAt := ""
+	if trans.ProcessedAt != nil {
+		processedAt = trans.ProcessedAt.Format(time.RFC3339Nano)
+	}
+
+	return e.w.Write([]string{
+		trans.ID,
+		trans.UserID,
+		strconv.FormatFloat(trans.Amount, 'f', -1, 64),
+		trans.Currency,
+		strconv.FormatBool(trans.Done),
+		string(trans.Status),
+		trans.Timestamp.Format(time.RFC3339Nano),
+		processedAt,
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Close() error {
+	return e.Flush()
+}
+
+type ndjsonEncoder struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	buf := bufio.NewWriter(w)
+	return &ndjsonEncoder{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (e *ndjsonEncoder) Encode(trans *domain.Transaction) error {
+	return e.enc.Encode(trans)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.buf.Flush()
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return e.Flush()
+}
+
+type parquetRow struct {
+	ID          string     `parquet:"id"`
+	UserID      string     `parquet:"user_id"`
+	Amount      float64    `parquet:"amount"`
+	Currency    string     `parquet:"currency"`
+	Done        bool       `parquet:"done"`
+	Status      string     `parquet:"status"`
+	Timestamp   time.Time  `parquet:"timestamp"`
+	ProcessedAt *time.Time `parquet:"processed_at,optional"`
+}
+
+// parquetEncoder writes a row group every parquetRowGroupSize rows; the footer is written on Close.
+type parquetEncoder struct {
+	w *parquet.GenericWriter[parquetRow]
+}
+
+func newParquetEncoder(w io.Writer) *parquetEncoder {
+	return &parquetEncoder{w: parquet.NewGenericWriter[parquetRow](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize))}
+}
+
+func (e *parquetEncoder) Encode(trans *domain.Transaction) error {
+	_, err := e.w.Write([]parquetRow{{
+		ID:          trans.ID,
+		UserID:      trans.UserID,
+		Amount:      trans.Amount,
+		Currency:    trans.Currency,
+		Done:        trans.Done,
+		Status:      string(trans.Status),
+		Timestamp:   trans.Timestamp,
+		ProcessedAt: trans.ProcessedAt,
+	}})
+	return err
+}
+
+// Flush does nothing: flushing on every call would write tiny row groups.
+func (e *parquetEncoder) Flush() error {
+	return nil
+}
+
+func (e *parquetEncoder) Close() error {
+	return e.w.Close()
+}
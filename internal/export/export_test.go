@@ -0,0 +1,67 @@
+package export
+
+import (
+	"TransactiStream/internal/domain"
+	"bytes"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func testTransactions() []*domain.Transaction {
+	created := time.Date(2024, 7, 31, 20, 4, 33, 828556000, time.UTC)
+	processed := created.Add(1500 * time.Millisecond)
+
+	return []*domain.Transaction{
+		{ID: "id-1", UserID: "u1", Amount: 100.5, Currency: "USD", Done: true, Status: domain.StatusSucceeded, Timestamp: created, ProcessedAt: &processed},
+		{ID: "id-2", UserID: "u2", Amount: 7, Currency: "EUR", Status: domain.StatusPending, Timestamp: created},
+	}
+}
+
+func encode(t *testing.T, format string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(format, &buf)
+	assert.NoError(t, err)
+	for _, trans := range testTransactions() {
+		assert.NoError(t, enc.Encode(trans))
+	}
+	assert.NoError(t, enc.Close())
+	return buf.Bytes()
+}
+
+func TestEncoder_CSV(t *testing.T) {
+	assert.Equal(t, "id,user_id,amount,currency,done,status,timestamp,processed_at\n"+
+		"id-1,u1,100.5,USD,true,succeeded,2024-07-31T20:04:33.828556Z,2024-07-31T20:04:35.328556Z\n"+
+		"id-2,u2,7,EUR,false,pending,2024-07-31T20:04:33.828556Z,\n",
+		string(encode(t, FormatCSV)))
+}
+
+func TestEncoder_NDJSON(t *testing.T) {
+	assert.Equal(t, `{"id":"id-1","user_id":"u1","amount":100.5,"currency":"USD","done":true,"status":"succeeded","timestamp":"2024-07-31T20:04:33.828556Z","processed_at":"2024-07-31T20:04:35.328556Z"}`+"\n"+
+		`{"id":"id-2","user_id":"u2","amount":7,"currency":"EUR","done":false,"status":"pending","timestamp":"2024-07-31T20:04:33.828556Z"}`+"\n",
+		string(encode(t, FormatNDJSON)))
+}
+
+func TestEncoder_Parquet(t *testing.T) {
+	data := encode(t, FormatParquet)
+
+	rows, err := parquet.Read[parquetRow](bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+
+	want := testTransactions()
+	assert.Equal(t, want[0].ID, rows[0].ID)
+	assert.Equal(t, want[0].Amount, rows[0].Amount)
+	assert.True(t, want[0].Timestamp.Equal(rows[0].Timestamp))
+	assert.True(t, want[0].ProcessedAt.Equal(*rows[0].ProcessedAt))
+	assert.Equal(t, "pending", rows[1].Status)
+	assert.Nil(t, rows[1].ProcessedAt)
+}
+
+func TestNewEncoder_UnknownFormat(t *testing.T) {
+	_, err := NewEncoder("xml", &bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,179 @@
+package importer
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/logger"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Supported file formats.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+type Store interface {
+	CreateBatch(ctx context.Context, transactions []*domain.Transaction) error
+	MarkUnpublished(ctx context.Context, ids []string) error
+}
+
+type Publisher interface {
+	SendMessages(ctx context.Context, transactions []*domain.Transaction) []error
+}
+
+// ReportFunc receives the rows of a chunk that were rejected or could not be published.
+type ReportFunc func(ctx context.Context, errs []domain.ImportError) error
+
+// ProgressFunc receives the import after every chunk, with its counters up to date.
+type ProgressFunc func(ctx context.Context, imp *domain.Import) error
+
+type Importer struct {
+	store     Store
+	publisher Publisher
+	validator *domain.Validator
+	chunkSize int
+}
+
+func New(store Store, publisher Publisher, validator *domain.Validator, chunkSize int) *Importer {
+	return &Importer{
+		store:     store,
+		publisher: publisher,
+		validator: validator,
+		chunkSize: chunkSize,
+	}
+}
+
+// Run streams transactions in imp.Format from r, storing valid rows chunkSize at a time and
+// publishing them unless imp.SkipPublish is set. It keeps the counters of imp up to date and
+// passes imp to progress, if set, after every chunk. On error the chunks stored so far are kept.
+func (im *Importer) Run(ctx context.Context, r io.Reader, imp *domain.Import, report ReportFunc, progress ProgressFunc) error {
+	rows, err := newReader(imp.Format, r)
+	if err != nil {
+		return err
+	}
+
+	var (
+		chunk    = make([]*domain.Transaction, 0, im.chunkSize)
+		accepted = make([]*row, 0, im.chunkSize)
+		rejected []domain.ImportError
+	)
+
+	flush := func() error {
+		if len(chunk) == 0 && len(rejected) == 0 {
+			return nil
+		}
+
+		if len(chunk) > 0 {
+			if err := im.store.CreateBatch(ctx, chunk); err != nil {
+				return fmt.Errorf("failed to store rows %d-%d: %w", accepted[0].line, accepted[len(accepted)-1].line, err)
+			}
+			imp.Imported += len(chunk)
+
+			if !imp.SkipPublish {
+				var unpublished []string
+				for i, err := range im.publisher.SendMessages(ctx, chunk) {
+					if err != nil {
+						imp.PublishFailed++
+						unpublished = append(unpublished, chunk[i].ID)
+						rejected = append(rejected, domain.ImportError{
+							Line:   accepted[i].line,
+							Raw:    accepted[i].raw,
+							Errors: []domain.FieldError{{Message: "stored as " + chunk[i].ID + " but could not be published"}},
+						})
+					}
+				}
+				if len(unpublished) > 0 {
+					if err := im.store.MarkUnpublished(ctx, unpublished); err != nil {
+						return fmt.Errorf("failed to mark rows as unpublished: %w", err)
+					}
+				}
+			}
+		}
+
+		if len(rejected) > 0 {
+			sort.Slice(rejected, func(i, j int) bool { return rejected[i].Line < rejected[j].Line })
+			if err := report(ctx, rejected); err != nil {
+				return fmt.Errorf("failed to report rejected rows: %w", err)
+			}
+		}
+		if progress != nil {
+			if err := progress(ctx, imp); err != nil {
+				return fmt.Errorf("failed to save progress: %w", err)
+			}
+		}
+
+		logger.FromContext(ctx).Debug().Int("stored", len(chunk)).Int("rejected", len(rejected)).Msg("Import chunk flushed")
+
+		chunk = chunk[:0]
+		accepted = accepted[:0]
+		rejected = nil
+		return nil
+	}
+
+	for {
+		rec, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		imp.Total++
+
+		im.check(rec, imp.SkipPublish)
+		if len(rec.errs.Fields) > 0 {
+			imp.Rejected++
+			rejected = append(rejected, domain.ImportError{Line: rec.line, Raw: rec.raw, Errors: rec.errs.Fields})
+		} else {
+			chunk = append(chunk, rec.trans)
+			accepted = append(accepted, rec)
+		}
+
+		if len(chunk)+len(rejected) >= im.chunkSize {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+// check validates rec like a submitted transaction; processing results are only
+// accepted for history that is not going to be published.
+func (im *Importer) check(rec *row, skipPublish bool) {
+	if rec.trans == nil {
+		return
+	}
+
+	// a value that failed to parse is reported once, not again for being empty
+	unparsed := make(map[string]bool, len(rec.errs.Fields))
+	for _, f := range rec.errs.Fields {
+		unparsed[f.Field] = true
+	}
+	validation := &domain.ValidationError{}
+	im.validator.Validate(rec.trans, "", validation)
+	for _, f := range validation.Fields {
+		if !unparsed[f.Field] {
+			rec.errs.Add(f.Field, f.Message)
+		}
+	}
+
+	if !skipPublish {
+		if rec.hasDone {
+			rec.errs.Add("done", "is only allowed when publishing is skipped")
+		}
+		if rec.trans.ProcessedAt != nil {
+			rec.errs.Add("processed_at", "is only allowed when publishing is skipped")
+		}
+		return
+	}
+
+	if p := rec.trans.ProcessedAt; p != nil && !rec.trans.Timestamp.IsZero() && p.Before(rec.trans.Timestamp) {
+		rec.errs.Add("processed_at", "must not be before timestamp")
+	}
+}
@@ -0,0 +1,191 @@
+package importer
+
+import (
+	"TransactiStream/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+type fakeStore struct {
+	batches     [][]*domain.Transaction
+	stored      int
+	unpublished []string
+}
+
+func (f *fakeStore) CreateBatch(ctx context.Context, transactions []*domain.Transaction) error {
+	for _, trans := range transactions {
+		f.stored++
+		trans.ID = fmt.Sprintf("id-%d", f.stored)
+	}
+	f.batches = append(f.batches, transactions)
+	return nil
+}
+
+func (f *fakeStore) MarkUnpublished(ctx context.Context, ids []string) error {
+	f.unpublished = append(f.unpublished, ids...)
+	return nil
+}
+
+type fakePublisher struct {
+	fail map[string]bool
+}
+
+func (f *fakePublisher) SendMessages(ctx context.Context, transactions []*domain.Transaction) []error {
+	errs := make([]error, len(transactions))
+	for i, trans := range transactions {
+		if f.fail[trans.ID] {
+			errs[i] = domain.ErrUnavailable
+		}
+	}
+	return errs
+}
+
+func newTestImporter(store Store, publisher Publisher, chunkSize int) *Importer {
+	validator, _ := domain.NewValidator(domain.ValidationRules{
+		UserIDPattern: `[a-z0-9]{1,16}`,
+		DefaultLimits: domain.AmountLimits{Min: 0.01, Max: 1000},
+	})
+	return New(store, publisher, validator, chunkSize)
+}
+
+func run(t *testing.T, im *Importer, imp *domain.Import, input string) ([]domain.ImportError, error) {
+	t.Helper()
+
+	var reported []domain.ImportError
+	err := im.Run(context.Background(), strings.NewReader(input), imp, func(_ context.Context, errs []domain.ImportError) error {
+		reported = append(reported, errs...)
+		return nil
+	}, nil)
+	return reported, err
+}
+
+func TestRun_CSVInChunks(t *testing.T) {
+	store := &fakeStore{}
+	imp := &domain.Import{Format: FormatCSV}
+
+	reported, err := run(t, newTestImporter(store, &fakePublisher{}, 2), imp,
+		"currency,user_id,amount\n"+
+			"USD,u1,10\n"+
+			"USD,u2,20\n"+
+			"USD,u3\n"+
+			"eur,u4,30\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, imp.Total)
+	assert.Equal(t, 3, imp.Imported)
+	assert.Equal(t, 1, imp.Rejected)
+	assert.Len(t, store.batches, 2)
+	assert.Equal(t, "EUR", store.batches[1][0].Currency)
+	assert.Equal(t, []domain.ImportError{{
+		Line:   4,
+		Raw:    "USD,u3",
+		Errors: []domain.FieldError{{Message: "wrong number of fields"}},
+	}}, reported)
+}
+
+func TestRun_SavesProgressPerChunk(t *testing.T) {
+	imp := &domain.Import{Format: FormatCSV}
+
+	var saved []domain.Import
+	err := newTestImporter(&fakeStore{}, &fakePublisher{}, 2).Run(context.Background(), strings.NewReader(
+		"currency,user_id,amount\n"+
+			"USD,u1,10\n"+
+			"USD,u2,20\n"+
+			"USD,u3\n"+
+			"eur,u4,30\n"), imp,
+		func(context.Context, []domain.ImportError) error { return nil },
+		func(_ context.Context, imp *domain.Import) error {
+			saved = append(saved, *imp)
+			return nil
+		})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Import{
+		{Format: FormatCSV, Total: 2, Imported: 2},
+		{Format: FormatCSV, Total: 4, Imported: 3, Rejected: 1},
+	}, saved)
+}
+
+func TestRun_CSVHeader(t *testing.T) {
+	_, err := run(t, newTestImporter(&fakeStore{}, &fakePublisher{}, 10), &domain.Import{Format: FormatCSV},
+		"user_id,amount,note\n")
+
+	var validation *domain.ValidationError
+	assert.True(t, errors.As(err, &validation))
+	assert.Equal(t, []domain.FieldError{
+		{Field: "header", Message: `column "note" is not allowed`},
+		{Field: "header", Message: `column "currency" is required`},
+	}, validation.Fields)
+}
+
+func TestRun_NDJSON(t *testing.T) {
+	imp := &domain.Import{Format: FormatNDJSON}
+
+	reported, err := run(t, newTestImporter(&fakeStore{}, &fakePublisher{}, 10), imp,
+		`{"user_id": "u1", "amount": 10, "currency": "USD"}`+"\n"+
+			"\n"+
+			`{"user_id": "u2", "amount": "10", "currency": "USD"}`+"\n"+
+			`{"user_id": "u3", "amount": 10, "currency": "USD", "note": "x"}`+"\n"+
+			`{"user_id": "u4", "amount": 10`+"\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, imp.Total)
+	assert.Equal(t, 1, imp.Imported)
+	assert.Equal(t, []domain.ImportError{
+		{Line: 3, Raw: `{"user_id": "u2", "amount": "10", "currency": "USD"}`, Errors: []domain.FieldError{{Field: "amount", Message: "must be a number"}}},
+		{Line: 4, Raw: `{"user_id": "u3", "amount": 10, "currency": "USD", "note": "x"}`, Errors: []domain.FieldError{{Field: "note", Message: "is not allowed"}}},
+		{Line: 5, Raw: `{"user_id": "u4", "amount": 10`, Errors: []domain.FieldError{{Message: "line is not valid JSON"}}},
+	}, reported)
+}
+
+func TestRun_ProcessedHistory(t *testing.T) {
+	input := "user_id,amount,currency,timestamp,done,processed_at\n" +
+		"u1,10,USD,2024-01-01T10:00:00Z,true,2024-01-01T10:00:05Z\n" +
+		"u2,10,USD,2024-01-01T10:00:00Z,false,2024-01-01T09:00:00Z\n"
+
+	t.Run("published", func(t *testing.T) {
+		imp := &domain.Import{Format: FormatCSV}
+		reported, err := run(t, newTestImporter(&fakeStore{}, &fakePublisher{}, 10), imp, input)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, imp.Rejected)
+		assert.Equal(t, []domain.FieldError{
+			{Field: "done", Message: "is only allowed when publishing is skipped"},
+			{Field: "processed_at", Message: "is only allowed when publishing is skipped"},
+		}, reported[0].Errors)
+	})
+
+	t.Run("skip publish", func(t *testing.T) {
+		store := &fakeStore{}
+		imp := &domain.Import{Format: FormatCSV, SkipPublish: true}
+		// a nil publisher must not be called when publishing is skipped
+		reported, err := run(t, newTestImporter(store, nil, 10), imp, input)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, imp.Imported)
+		assert.True(t, store.batches[0][0].Done)
+		assert.NotNil(t, store.batches[0][0].ProcessedAt)
+		assert.Equal(t, []domain.FieldError{{Field: "processed_at", Message: "must not be before timestamp"}}, reported[0].Errors)
+	})
+}
+
+func TestRun_PublishFailed(t *testing.T) {
+	imp := &domain.Import{Format: FormatCSV}
+	store := &fakeStore{}
+	reported, err := run(t, newTestImporter(store, &fakePublisher{fail: map[string]bool{"id-2": true}}, 10), imp,
+		"user_id,amount,currency\nu1,10,USD\nu2,20,USD\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, imp.Imported)
+	assert.Equal(t, 1, imp.PublishFailed)
+	assert.Equal(t, []string{"id-2"}, store.unpublished)
+	assert.Equal(t, []domain.ImportError{{
+		Line:   3,
+		Raw:    "u2,20,USD",
+		Errors: []domain.FieldError{{Message: "stored as id-2 but could not be published"}},
+	}}, reported)
+}
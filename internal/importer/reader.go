@@ -0,0 +1,258 @@
+package importer
+
+import (
+	"TransactiStream/internal/domain"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLineBytes bounds a single NDJSON line.
+const maxLineBytes = 1 << 20
+
+// row is one data row of a file; errs holds the problems found so far.
+type row struct {
+	line    int
+	raw     string
+	trans   *domain.Transaction
+	hasDone bool
+	errs    *domain.ValidationError
+}
+
+type rowReader interface {
+	// next returns io.EOF after the last row and any other error if the file can't be read further.
+	next() (*row, error)
+}
+
+func newReader(format string, r io.Reader) (rowReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		return newNDJSONReader(r), nil
+	}
+	return nil, formatError(fmt.Sprintf("must be %s or %s", FormatCSV, FormatNDJSON))
+}
+
+func formatError(message string) error {
+	validation := &domain.ValidationError{}
+	validation.Add("format", message)
+	return validation
+}
+
+var (
+	csvRequired = []string{"user_id", "amount", "currency"}
+	csvOptional = []string{"timestamp", "done", "processed_at"}
+)
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+// newCSVReader reads the header, which names the columns in any order.
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, formatError("file is empty")
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, formatError("header is not valid CSV: " + parseErr.Err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	known := map[string]bool{}
+	for _, name := range append(csvRequired, csvOptional...) {
+		known[name] = true
+	}
+
+	validation := &domain.ValidationError{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, dup := columns[name]; dup {
+			validation.Add("header", fmt.Sprintf("column %q is repeated", name))
+		}
+		if !known[name] {
+			validation.Add("header", fmt.Sprintf("column %q is not allowed", name))
+		}
+		columns[name] = i
+	}
+	for _, name := range csvRequired {
+		if _, ok := columns[name]; !ok {
+			validation.Add("header", fmt.Sprintf("column %q is required", name))
+		}
+	}
+	if err = validation.Err(); err != nil {
+		return nil, err
+	}
+
+	cr.FieldsPerRecord = len(header)
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (c *csvReader) next() (*row, error) {
+	fields, err := c.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+
+	rec := &row{raw: encodeCSV(fields), errs: &domain.ValidationError{}}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		rec.line = parseErr.StartLine
+		rec.errs.Add("", parseErr.Err.Error())
+		return rec, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	rec.line, _ = c.r.FieldPos(0)
+
+	get := func(name string) string {
+		if i, ok := c.columns[name]; ok {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+
+	trans := &domain.Transaction{
+		UserID:   get("user_id"),
+		Currency: strings.ToUpper(get("currency")),
+	}
+	if v := get("amount"); v != "" {
+		if trans.Amount, err = strconv.ParseFloat(v, 64); err != nil {
+			rec.errs.Add("amount", "must be a number")
+		}
+	}
+	if v := get("timestamp"); v != "" {
+		if trans.Timestamp, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			rec.errs.Add("timestamp", "must be a RFC 3339 timestamp")
+		}
+	}
+	if v := get("done"); v != "" {
+		rec.hasDone = true
+		if trans.Done, err = strconv.ParseBool(v); err != nil {
+			rec.errs.Add("done", "must be a boolean")
+		}
+	}
+	if v := get("processed_at"); v != "" {
+		processedAt, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			rec.errs.Add("processed_at", "must be a RFC 3339 timestamp")
+		} else {
+			trans.ProcessedAt = &processedAt
+		}
+	}
+
+	rec.trans = trans
+	return rec, nil
+}
+
+func encodeCSV(fields []string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(fields)
+	w.Flush()
+	return strings.TrimRight(b.String(), "\r\n")
+}
+
+// jsonRow is one line of an NDJSON file.
+type jsonRow struct {
+	UserID      string     `json:"user_id"`
+	Amount      float64    `json:"amount"`
+	Currency    string     `json:"currency"`
+	Timestamp   time.Time  `json:"timestamp"`
+	Done        *bool      `json:"done"`
+	ProcessedAt *time.Time `json:"processed_at"`
+}
+
+type ndjsonReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	return &ndjsonReader{s: s}
+}
+
+func (n *ndjsonReader) next() (*row, error) {
+	for n.s.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.s.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		rec := &row{line: n.line, raw: string(data), errs: &domain.ValidationError{}}
+
+		var v jsonRow
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&v); err != nil {
+			rec.errs.Fields = jsonErrors(err)
+			return rec, nil
+		}
+		if dec.More() {
+			rec.errs.Add("", "line must contain a single JSON object")
+			return rec, nil
+		}
+
+		rec.trans = &domain.Transaction{
+			UserID:      v.UserID,
+			Amount:      v.Amount,
+			Currency:    strings.ToUpper(v.Currency),
+			Timestamp:   v.Timestamp,
+			ProcessedAt: v.ProcessedAt,
+		}
+		if v.Done != nil {
+			rec.hasDone = true
+			rec.trans.Done = *v.Done
+		}
+		return rec, nil
+	}
+
+	if err := n.s.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, formatError(fmt.Sprintf("line %d is longer than %d bytes", n.line+1, maxLineBytes))
+		}
+		return nil, fmt.Errorf("failed to read NDJSON: %w", err)
+	}
+	return nil, io.EOF
+}
+
+func jsonErrors(err error) []domain.FieldError {
+	if field, ok := domain.JSONFieldError(err); ok {
+		return []domain.FieldError{field}
+	}
+
+	var (
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+
+	switch {
+	case errors.As(err, &typeErr):
+		return []domain.FieldError{{Message: "line must be a JSON object"}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return []domain.FieldError{{Message: "line is not valid JSON"}}
+	}
+	// time.Time reports malformed timestamps without naming the field
+	return []domain.FieldError{{Message: "line contains an invalid value"}}
+}
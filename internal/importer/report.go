@@ -0,0 +1,39 @@
+package importer
+
+import (
+	"TransactiStream/internal/domain"
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// CSVReport writes rejected rows as CSV with one record per problem.
+type CSVReport struct {
+	w *csv.Writer
+}
+
+func NewCSVReport(w io.Writer) (*CSVReport, error) {
+	r := &CSVReport{w: csv.NewWriter(w)}
+	if err := r.w.Write([]string{"line", "field", "message", "raw"}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CSVReport) Write(errs []domain.ImportError) error {
+	for _, e := range errs {
+		for _, f := range e.Errors {
+			if err := r.w.Write([]string{strconv.Itoa(e.Line), f.Field, f.Message, e.Raw}); err != nil {
+				return err
+			}
+		}
+	}
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// Flush writes any buffered records and reports a previous write error.
+func (r *CSVReport) Flush() error {
+	r.w.Flush()
+	return r.w.Error()
+}
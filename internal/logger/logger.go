@@ -1,30 +1,91 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"github.com/rs/zerolog"
+	"io"
 	"os"
+	"strings"
+)
+
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
 )
 
 var Log zerolog.Logger
 
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// InitLogger sets up a console logger at info level, used until the config is loaded.
 func InitLogger() {
-	output := zerolog.ConsoleWriter{Out: os.Stderr}
-	Log = zerolog.New(output).With().Timestamp().Logger()
+	Log = newLogger(os.Stderr, FormatConsole, zerolog.InfoLevel)
+}
+
+// Setup replaces the global logger according to the configured level and format.
+func Setup(level, format string) error {
+	lvl, err := zerolog.ParseLevel(strings.ToLower(level))
+	if err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	switch format {
+	case FormatConsole, FormatJSON:
+	default:
+		return fmt.Errorf("invalid log format %q", format)
+	}
+
+	Log = newLogger(os.Stderr, format, lvl)
+	return nil
+}
+
+func newLogger(out io.Writer, format string, level zerolog.Level) zerolog.Logger {
+	if format == FormatConsole {
+		out = zerolog.ConsoleWriter{Out: out}
+	}
+
+	// redaction runs on the JSON produced by zerolog, before console formatting
+	return zerolog.New(redactWriter{out}).Level(level).With().Timestamp().Logger()
+}
+
+// FromContext returns the logger stored in ctx, or the global one.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*zerolog.Logger); ok {
+		return l
+	}
+	return &Log
+}
+
+// With returns a copy of ctx whose logger carries the fields added by fn.
+func With(ctx context.Context, fn func(zerolog.Context) zerolog.Context) context.Context {
+	l := fn(FromContext(ctx).With()).Logger()
+	return context.WithValue(ctx, loggerKey{}, &l)
 }
 
-func Info(msg string) {
-	Log.Info().Msg(msg)
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return With(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("request_id", id)
+	})
 }
 
-func Infof(msg string, args ...interface{}) {
-	Log.Info().Msg(fmt.Sprintf(msg, args...))
+// RequestID returns the request ID stored by WithRequestID.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
 }
 
-func Error(msg string) {
-	Log.Error().Msg(msg)
+func WithTransactionID(ctx context.Context, id string) context.Context {
+	return With(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("transaction_id", id)
+	})
 }
 
-func Errorf(msg string, args ...interface{}) {
-	Log.Error().Msg(fmt.Sprintf(msg, args...))
+func WithKafkaMessage(ctx context.Context, topic string, partition int, offset int64) context.Context {
+	return With(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("topic", topic).Int("partition", partition).Int64("offset", offset)
+	})
 }
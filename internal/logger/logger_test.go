@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFromContext_CarriesFields(t *testing.T) {
+	var buf bytes.Buffer
+	Log = newLogger(&buf, FormatJSON, zerolog.InfoLevel)
+	defer InitLogger()
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = WithKafkaMessage(ctx, "processed", 2, 42)
+	ctx = WithTransactionID(ctx, "tx-1")
+
+	FromContext(ctx).Info().Str("dsn", "postgres://u:secret@db/x").Msg("hello")
+	FromContext(ctx).Debug().Msg("below level")
+
+	var line map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "hello", line["message"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Equal(t, "tx-1", line["transaction_id"])
+	assert.Equal(t, "processed", line["topic"])
+	assert.Equal(t, float64(2), line["partition"])
+	assert.Equal(t, float64(42), line["offset"])
+	assert.Equal(t, "postgres://u:[REDACTED]@db/x", line["dsn"])
+	assert.Equal(t, "req-1", RequestID(ctx))
+}
+
+func TestFromContext_FallsBackToGlobal(t *testing.T) {
+	assert.Same(t, &Log, FromContext(context.Background()))
+}
+
+func TestSetup_RejectsUnknownValues(t *testing.T) {
+	defer InitLogger()
+
+	assert.NoError(t, Setup("debug", FormatJSON))
+	assert.Error(t, Setup("loud", FormatJSON))
+	assert.Error(t, Setup("info", "xml"))
+}
@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRedactDSN(t *testing.T) {
+	assert.Equal(t, "postgres://postgres:[REDACTED]@db:5432/transactions",
+		RedactDSN("postgres://postgres:password@db:5432/transactions"))
+	assert.Equal(t, "postgres://db:5432/transactions",
+		RedactDSN("postgres://db:5432/transactions"))
+	assert.Equal(t, "host=db user=postgres password=[REDACTED] dbname=transactions",
+		RedactDSN("host=db user=postgres password=hunter2 dbname=transactions"))
+}
+
+func TestRedact(t *testing.T) {
+	cases := map[string]string{
+		"failed to connect to postgres://u:p4ss@db/x": "failed to connect to postgres://u:[REDACTED]@db/x",
+		"GET /cb?token=abc123&x=1":                    "GET /cb?token=[REDACTED]&x=1",
+		`{"secret": "s3", "id": "1"}`:                 `{"secret": "[REDACTED]", "id": "1"}`,
+		"Authorization: Bearer eyJhbGciOi.x-y_z":      "Authorization: Bearer [REDACTED]",
+		"nothing to hide":                             "nothing to hide",
+	}
+
+	for in, want := range cases {
+		assert.Equal(t, want, Redact(in))
+	}
+}
+
+func TestPII(t *testing.T) {
+	defer SetPIIFields(DefaultPIIFields)
+
+	assert.Equal(t, Redacted, PII("user_id", "user1"))
+	assert.Equal(t, "BTC", PII("currency", "BTC"))
+
+	SetPIIFields([]string{"currency"})
+	assert.Equal(t, "user1", PII("user_id", "user1"))
+	assert.Equal(t, Redacted, PII("currency", "BTC"))
+}
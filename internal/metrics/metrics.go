@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "transactistream"
+
+// Registry holds every collector of the service together with Go runtime and process metrics.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	HTTPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	KafkaPublishDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "publish_duration_seconds",
+		Help:      "Latency of writing transactions to Kafka.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	KafkaPublishErrors = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "publish_errors_total",
+		Help:      "Failed writes of transactions to Kafka.",
+	})
+
+	KafkaConsumed = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumed_messages_total",
+		Help:      "Processed-transaction messages handled by the consumer, by result.",
+	}, []string{"result"})
+
+	KafkaConsumerLag = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages behind the high watermark per partition, as of the last consumed message.",
+	}, []string{"topic", "partition"})
+
+	RepositoryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "query_duration_seconds",
+		Help:      "Repository call latency by method and result.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method", "result"})
+
+	TransactionProcessing = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "transaction",
+		Name:      "processing_seconds",
+		Help:      "Time from transaction creation until its result is stored, by outcome.",
+		Buckets:   []float64{.1, .25, .5, 1, 2, 3, 5, 7.5, 10, 15, 30, 60, 120},
+	}, []string{"done"})
+
+	WebhookDeliveries = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "delivery_attempts_total",
+		Help:      "Webhook delivery attempts by result: succeeded, retry or failed.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveQuery records the latency of a repository call started at start.
+// Intended to be deferred with a pointer to the named error result.
+func ObserveQuery(method string, start time.Time, err *error) {
+	result := "ok"
+	if err != nil && *err != nil {
+		result = "error"
+	}
+	RepositoryDuration.WithLabelValues(method, result).Observe(time.Since(start).Seconds())
+}
+
+// ObserveConsumed records the outcome of one consumed message and the partition lag it reveals.
+func ObserveConsumed(topic string, partition int, offset, highWaterMark int64, result string) {
+	KafkaConsumed.WithLabelValues(result).Inc()
+
+	if highWaterMark > 0 {
+		lag := highWaterMark - offset - 1
+		if lag < 0 {
+			lag = 0
+		}
+		KafkaConsumerLag.WithLabelValues(topic, strconv.Itoa(partition)).Set(float64(lag))
+	}
+}
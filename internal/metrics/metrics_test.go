@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestObserveQuery_LabelsResult(t *testing.T) {
+	func() (err error) {
+		defer ObserveQuery("TestObserveQuery", time.Now(), &err)
+		return nil
+	}()
+	func() (err error) {
+		defer ObserveQuery("TestObserveQuery", time.Now(), &err)
+		return errors.New("boom")
+	}()
+	func() (err error) {
+		defer ObserveQuery("TestObserveQuery", time.Now(), &err)
+		return errors.New("boom")
+	}()
+
+	assert.Equal(t, uint64(1), sampleCount(t, RepositoryDuration.WithLabelValues("TestObserveQuery", "ok")))
+	assert.Equal(t, uint64(2), sampleCount(t, RepositoryDuration.WithLabelValues("TestObserveQuery", "error")))
+}
+
+func TestObserveConsumed(t *testing.T) {
+	tests := []struct {
+		name          string
+		partition     int
+		offset        int64
+		highWaterMark int64
+		wantLag       float64
+	}{
+		{name: "behind", partition: 0, offset: 5, highWaterMark: 10, wantLag: 4},
+		{name: "caught up", partition: 1, offset: 9, highWaterMark: 10, wantLag: 0},
+		{name: "stale watermark", partition: 2, offset: 12, highWaterMark: 10, wantLag: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(KafkaConsumed.WithLabelValues("ok"))
+
+			ObserveConsumed("TestObserveConsumed", tt.partition, tt.offset, tt.highWaterMark, "ok")
+
+			assert.Equal(t, before+1, testutil.ToFloat64(KafkaConsumed.WithLabelValues("ok")))
+			lag := KafkaConsumerLag.WithLabelValues("TestObserveConsumed", []string{"0", "1", "2"}[tt.partition])
+			assert.Equal(t, tt.wantLag, testutil.ToFloat64(lag))
+		})
+	}
+}
+
+func TestObserveConsumed_UnknownWatermark(t *testing.T) {
+	before := testutil.CollectAndCount(KafkaConsumerLag)
+
+	ObserveConsumed("TestObserveConsumed_UnknownWatermark", 0, 5, 0, "error")
+
+	assert.Equal(t, before, testutil.CollectAndCount(KafkaConsumerLag))
+}
+
+func sampleCount(t *testing.T, o prometheus.Observer) uint64 {
+	t.Helper()
+
+	var m dto.Metric
+	require.NoError(t, o.(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
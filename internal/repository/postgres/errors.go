@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"TransactiStream/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"net"
+	"strings"
+)
+
+const (
+	uniqueViolation    = "23505"
+	invalidTextRepr    = "22P02"
+	connectionFailures = "08"
+)
+
+// mapError wraps err with the domain error it stands for, keeping the original for logs.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		pgErr      *pgconn.PgError
+		connectErr *pgconn.ConnectError
+		netErr     net.Error
+	)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("%w: %w", domain.ErrNotFound, err)
+	case errors.As(err, &pgErr) && pgErr.Code == uniqueViolation:
+		return fmt.Errorf("%w: %w", domain.ErrConflict, err)
+	case errors.As(err, &pgErr) && pgErr.Code == invalidTextRepr:
+		// a malformed UUID can't identify an existing row
+		return fmt.Errorf("%w: %w", domain.ErrNotFound, err)
+	case errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, connectionFailures),
+		errors.As(err, &connectErr),
+		errors.As(err, &netErr),
+		errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", domain.ErrUnavailable, err)
+	}
+
+	return err
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/metrics"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+const eventColumns = `seq, transaction_id, user_id, done, processed_at, created_at`
+
+// recordStatusChange appends the current processing result of a transaction to the event log
+// and announces it on EventChannel; the notification is sent only if tx commits.
+func recordStatusChange(ctx context.Context, tx pgx.Tx, id string) (*domain.TransactionEvent, error) {
+	ev, err := scanEvent(tx.QueryRow(ctx, `
+		INSERT INTO transaction_events (transaction_id, user_id, done, processed_at)
+		SELECT id, user_id, done, processed_at FROM transactions WHERE id = $1
+		RETURNING `+eventColumns, id))
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if _, err = tx.Exec(ctx, `SELECT pg_notify($1, $2)`, EventChannel, string(payload)); err != nil {
+		return nil, mapError(err)
+	}
+
+	return ev, nil
+}
+
+// lastEventSeq returns the highest seq in the event log, 0 if it is empty.
+func (p *Postgres) lastEventSeq(ctx context.Context) (seq int64, err error) {
+	defer metrics.ObserveQuery("LastEventSeq", time.Now(), &err)
+
+	err = p.db.QueryRow(ctx, `SELECT COALESCE(MAX(seq), 0) FROM transaction_events`).Scan(&seq)
+	return seq, mapError(err)
+}
+
+// ReadEvents calls fn for every event matching filter that may have committed after the event
+// with seq after, in seq order. Seqs are assigned before commit, so that is every event of a
+// transaction not yet finished when after was recorded rather than every higher seq; an event
+// committed shortly before after may be passed again. Without a recorded event after, it falls
+// back to the higher seqs.
+func (p *Postgres) ReadEvents(ctx context.Context, after int64, filter domain.EventFilter, fn func(domain.TransactionEvent) error) (err error) {
+	defer metrics.ObserveQuery("ReadEvents", time.Now(), &err)
+
+	// transactions below the xmin of the snapshot of after had finished before after committed
+	query := `SELECT ` + eventColumns + ` FROM transaction_events
+		WHERE seq <> $1 AND COALESCE(xid >= (SELECT snapshot_xmin FROM transaction_events WHERE seq = $1), seq > $1)`
+	args := []any{after}
+	if !filter.Empty() {
+		query += ` AND (user_id = ANY($2) OR transaction_id::text = ANY($3))`
+		args = append(args, filter.UserIDs, filter.TransactionIDs)
+	}
+
+	rows, err := p.db.Query(ctx, query+` ORDER BY seq`, args...)
+	if err != nil {
+		return mapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return mapError(err)
+		}
+		if err = fn(*ev); err != nil {
+			return err
+		}
+	}
+
+	return mapError(rows.Err())
+}
+
+func scanEvent(row pgx.Row) (*domain.TransactionEvent, error) {
+	ev := &domain.TransactionEvent{}
+	err := row.Scan(&ev.Seq, &ev.TransactionID, &ev.UserID, &ev.Done, &ev.ProcessedAt, &ev.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	ev.Status = domain.StatusOf(ev.Done, ev.ProcessedAt)
+	return ev, nil
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"TransactiStream/internal/domain"
+	"strconv"
+	"strings"
+)
+
+// filterSQL renders f as a WHERE clause, or an empty string when f matches everything.
+func filterSQL(f domain.TransactionFilter) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if f.UserID != "" {
+		conds = append(conds, "user_id = "+arg(f.UserID))
+	}
+	if f.Currency != "" {
+		conds = append(conds, "currency = "+arg(f.Currency))
+	}
+	switch f.Status {
+	case domain.StatusPending:
+		conds = append(conds, "processed_at IS NULL")
+	case domain.StatusSucceeded:
+		conds = append(conds, "processed_at IS NOT NULL AND done")
+	case domain.StatusFailed:
+		conds = append(conds, "processed_at IS NOT NULL AND NOT done")
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "created_at >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "created_at < "+arg(f.To))
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
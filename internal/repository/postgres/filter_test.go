@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"TransactiStream/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFilterSQL(t *testing.T) {
+	from := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	where, args := filterSQL(domain.TransactionFilter{})
+	assert.Empty(t, where)
+	assert.Empty(t, args)
+
+	where, args = filterSQL(domain.TransactionFilter{
+		UserID: "u1",
+		Status: domain.StatusFailed,
+		From:   from,
+	})
+	assert.Equal(t, " WHERE user_id = $1 AND processed_at IS NOT NULL AND NOT done AND created_at >= $2", where)
+	assert.Equal(t, []any{"u1", from}, args)
+}
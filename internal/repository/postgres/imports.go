@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/metrics"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+func (p *Postgres) CreateImport(ctx context.Context, imp *domain.Import) (err error) {
+	defer metrics.ObserveQuery("CreateImport", time.Now(), &err)
+
+	imp.Status = domain.ImportRunning
+	err = p.db.QueryRow(ctx, `INSERT INTO imports (format, skip_publish, status) VALUES ($1, $2, $3) RETURNING id, created_at`,
+		imp.Format, imp.SkipPublish, imp.Status).Scan(&imp.ID, &imp.CreatedAt)
+	if err != nil {
+		return mapError(err)
+	}
+
+	return nil
+}
+
+// FinishImport stores the final status and counters of imp.
+func (p *Postgres) FinishImport(ctx context.Context, imp *domain.Import) (err error) {
+	defer metrics.ObserveQuery("FinishImport", time.Now(), &err)
+
+	finishedAt := time.Now().UTC()
+	tag, err := p.db.Exec(ctx, `
+		UPDATE imports
+		SET status = $1, total = $2, imported = $3, rejected = $4, publish_failed = $5, error = NULLIF($6, ''),
+			finished_at = $7, updated_at = $7
+		WHERE id = $8`,
+		imp.Status, imp.Total, imp.Imported, imp.Rejected, imp.PublishFailed, imp.Error, finishedAt, imp.ID)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: import %s", domain.ErrNotFound, imp.ID)
+	}
+
+	imp.FinishedAt = &finishedAt
+	return nil
+}
+
+// SaveImportProgress stores the counters of a running import and marks it as alive. An import
+// that is no longer running, because FailStaleImports gave up on it, is not found.
+func (p *Postgres) SaveImportProgress(ctx context.Context, imp *domain.Import) (err error) {
+	defer metrics.ObserveQuery("SaveImportProgress", time.Now(), &err)
+
+	tag, err := p.db.Exec(ctx, `
+		UPDATE imports SET total = $1, imported = $2, rejected = $3, publish_failed = $4, updated_at = $5
+		WHERE id = $6 AND status = $7`,
+		imp.Total, imp.Imported, imp.Rejected, imp.PublishFailed, time.Now().UTC(), imp.ID, domain.ImportRunning)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: running import %s", domain.ErrNotFound, imp.ID)
+	}
+
+	return nil
+}
+
+// TouchImport marks a running import as alive.
+func (p *Postgres) TouchImport(ctx context.Context, id string) (err error) {
+	defer metrics.ObserveQuery("TouchImport", time.Now(), &err)
+
+	_, err = p.db.Exec(ctx, `UPDATE imports SET updated_at = $1 WHERE id = $2 AND status = $3`,
+		time.Now().UTC(), id, domain.ImportRunning)
+	return mapError(err)
+}
+
+// FailStaleImports marks the running imports not touched since before as failed and returns
+// how many there were.
+func (p *Postgres) FailStaleImports(ctx context.Context, before time.Time) (_ int, err error) {
+	defer metrics.ObserveQuery("FailStaleImports", time.Now(), &err)
+
+	now := time.Now().UTC()
+	tag, err := p.db.Exec(ctx, `
+		UPDATE imports SET status = $1, error = $2, finished_at = $3, updated_at = $3
+		WHERE status = $4 AND updated_at < $5`,
+		domain.ImportFailed, "import was interrupted", now, domain.ImportRunning, before.UTC())
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+func (p *Postgres) ReadImport(ctx context.Context, id string) (_ *domain.Import, err error) {
+	defer metrics.ObserveQuery("ReadImport", time.Now(), &err)
+
+	imp := &domain.Import{}
+	err = p.db.QueryRow(ctx, `
+		SELECT id, format, skip_publish, status, total, imported, rejected, publish_failed, COALESCE(error, ''), created_at, finished_at
+		FROM imports WHERE id = $1`, id).
+		Scan(&imp.ID, &imp.Format, &imp.SkipPublish, &imp.Status, &imp.Total, &imp.Imported, &imp.Rejected,
+			&imp.PublishFailed, &imp.Error, &imp.CreatedAt, &imp.FinishedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return imp, nil
+}
+
+func (p *Postgres) AddImportErrors(ctx context.Context, importID string, errs []domain.ImportError) (err error) {
+	defer metrics.ObserveQuery("AddImportErrors", time.Now(), &err)
+
+	id, err := uuid.Parse(importID)
+	if err != nil {
+		return fmt.Errorf("%w: import %s", domain.ErrNotFound, importID)
+	}
+
+	rows := make([][]any, 0, len(errs))
+	for _, e := range errs {
+		fields, err := json.Marshal(e.Errors)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []any{id, e.Line, e.Raw, string(fields)})
+	}
+
+	_, err = p.db.CopyFrom(ctx, pgx.Identifier{"import_errors"}, []string{"import_id", "line", "raw", "errors"}, pgx.CopyFromRows(rows))
+	if err != nil {
+		return mapError(err)
+	}
+
+	return nil
+}
+
+// ReadImportErrors calls fn for every recorded error of an import in line order without loading them all.
+func (p *Postgres) ReadImportErrors(ctx context.Context, importID string, fn func(domain.ImportError) error) (err error) {
+	defer metrics.ObserveQuery("ReadImportErrors", time.Now(), &err)
+
+	rows, err := p.db.Query(ctx, `SELECT line, raw, errors FROM import_errors WHERE import_id = $1 ORDER BY line`, importID)
+	if err != nil {
+		return mapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			e      domain.ImportError
+			fields []byte
+		)
+		if err = rows.Scan(&e.Line, &e.Raw, &fields); err != nil {
+			return mapError(err)
+		}
+		if err = json.Unmarshal(fields, &e.Errors); err != nil {
+			return fmt.Errorf("failed to decode errors of line %d: %w", e.Line, err)
+		}
+		if err = fn(e); err != nil {
+			return err
+		}
+	}
+
+	return mapError(rows.Err())
+}
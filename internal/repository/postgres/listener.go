@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/logger"
+	"TransactiStream/internal/retry"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// EventChannel is the notification channel SetProcessedAt announces events on.
+const EventChannel = "transaction_events"
+
+// Listener receives the status changes recorded by any instance of the service over
+// LISTEN/NOTIFY, so each instance can serve them to its own subscribers.
+type Listener struct {
+	connConfig *pgx.ConnConfig
+	repo       *Postgres
+	retry      retry.Policy
+}
+
+// NewListener listens on a dedicated connection made with connConfig; repo reads the events
+// recorded while that connection was down. The connection is retried with policy forever.
+func NewListener(connConfig *pgx.ConnConfig, repo *Postgres, policy retry.Policy) *Listener {
+	policy.MaxElapsedTime = 0
+	return &Listener{
+		connConfig: connConfig,
+		repo:       repo,
+		retry:      policy,
+	}
+}
+
+// Run passes every announced event to publish until ctx is done. After a reconnection the
+// events recorded in the meantime are read from the event log first, so none are lost; one
+// committed just before the connection dropped may be published twice.
+func (l *Listener) Run(ctx context.Context, publish func(domain.TransactionEvent)) {
+	var (
+		// last is the event a reconnection catches up after: the last one published, or the
+		// last in the log when first listening; -1 until then
+		last    int64 = -1
+		attempt int
+	)
+
+	for {
+		err := l.listen(ctx, &last, publish, func() { attempt = 0 })
+		if ctx.Err() != nil {
+			return
+		}
+
+		attempt++
+		delay := l.retry.Backoff(attempt)
+		logger.FromContext(ctx).Warn().
+			Err(err).
+			Int("attempt", attempt).
+			Dur("retry_in", delay).
+			Msg("Lost the transaction event feed, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// listen consumes notifications until the connection fails, calling connected once it listens.
+func (l *Listener) listen(ctx context.Context, last *int64, publish func(domain.TransactionEvent), connected func()) error {
+	conn, err := pgx.ConnectConfig(ctx, l.connConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err = conn.Exec(ctx, `LISTEN `+EventChannel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	connected()
+	logger.FromContext(ctx).Info().Str("channel", EventChannel).Msg("Listening for transaction events")
+
+	// events caught up from the log are announced as well; seen drops those notifications
+	seen := map[int64]struct{}{}
+	if *last < 0 {
+		// subscribers replay older events themselves; from here on a reconnection catches up
+		if *last, err = l.repo.lastEventSeq(ctx); err != nil {
+			return fmt.Errorf("failed to read the last event: %w", err)
+		}
+	} else {
+		err = l.repo.ReadEvents(ctx, *last, domain.EventFilter{}, func(ev domain.TransactionEvent) error {
+			seen[ev.Seq] = struct{}{}
+			*last = max(*last, ev.Seq)
+			publish(ev)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to catch up: %w", err)
+		}
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var ev domain.TransactionEvent
+		if err = json.Unmarshal([]byte(n.Payload), &ev); err != nil {
+			// the payload carries the user ID, so only its size is logged
+			logger.FromContext(ctx).Error().Err(err).Int("payload_bytes", len(n.Payload)).Msg("failed to decode transaction event")
+			continue
+		}
+		if _, ok := seen[ev.Seq]; ok {
+			delete(seen, ev.Seq)
+			continue
+		}
+		// notifications come in commit order, so none of a caught-up event follows one that
+		// was not caught up; the rest were committed before LISTEN and are never announced
+		clear(seen)
+
+		// the latest committed event, so the catch-up repeats as few events as possible
+		*last = ev.Seq
+		publish(ev)
+	}
+}
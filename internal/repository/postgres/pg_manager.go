@@ -3,9 +3,10 @@ package postgres
 import (
 	"context"
 	"github.com/jackc/pgx/v5"
+	"strconv"
 )
 
-func CreateTables(ctx context.Context, conn pgx.Conn) error {
+func CreateTables(ctx context.Context, conn DB) error {
 	var (
 		query string
 		err   error
@@ -28,11 +29,211 @@ func CreateTables(ctx context.Context, conn pgx.Conn) error {
 		processed_at TIMESTAMP,
 		processing_time INTERVAL
 		); 
+
+		ALTER TABLE transactions ADD COLUMN IF NOT EXISTS publish_failed BOOLEAN NOT NULL DEFAULT FALSE;
+
+		CREATE INDEX IF NOT EXISTS transactions_created_at_idx ON transactions (created_at);
+		CREATE INDEX IF NOT EXISTS transactions_unprocessed_idx ON transactions (created_at)
+			WHERE processed_at IS NULL AND NOT publish_failed;
+	`
+	_, err = conn.Exec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		CREATE TABLE IF NOT EXISTS imports (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		format VARCHAR(16) NOT NULL,
+		skip_publish BOOLEAN NOT NULL DEFAULT FALSE,
+		status VARCHAR(16) NOT NULL,
+		total INTEGER NOT NULL DEFAULT 0,
+		imported INTEGER NOT NULL DEFAULT 0,
+		rejected INTEGER NOT NULL DEFAULT 0,
+		publish_failed INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		finished_at TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS import_errors (
+		import_id UUID NOT NULL REFERENCES imports (id) ON DELETE CASCADE,
+		line INTEGER NOT NULL,
+		raw TEXT NOT NULL,
+		errors JSONB NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS import_errors_import_id_line_idx ON import_errors (import_id, line);
+
+		ALTER TABLE imports ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'UTC');
+		CREATE INDEX IF NOT EXISTS imports_running_idx ON imports (updated_at) WHERE status = 'running';
+	`
+	_, err = conn.Exec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		CREATE TABLE IF NOT EXISTS transaction_events (
+		seq BIGSERIAL PRIMARY KEY,
+		transaction_id UUID NOT NULL,
+		user_id VARCHAR(255) NOT NULL,
+		done BOOLEAN NOT NULL,
+		processed_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
 	`
 	_, err = conn.Exec(ctx, query)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// seq is assigned before commit, so resuming after an event goes by the transactions that
+	// were still running when it was recorded; events recorded before these columns have none
+	query = `
+		ALTER TABLE transaction_events ADD COLUMN IF NOT EXISTS xid xid8;
+		ALTER TABLE transaction_events ADD COLUMN IF NOT EXISTS snapshot_xmin xid8;
+		ALTER TABLE transaction_events ALTER COLUMN xid SET DEFAULT pg_current_xact_id();
+		ALTER TABLE transaction_events ALTER COLUMN snapshot_xmin SET DEFAULT pg_snapshot_xmin(pg_current_snapshot());
+
+		CREATE INDEX IF NOT EXISTS transaction_events_xid_idx ON transaction_events (xid);
+	`
+	_, err = conn.Exec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		CREATE TABLE IF NOT EXISTS webhooks (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		event_types TEXT[] NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		webhook_id UUID NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+		event_type VARCHAR(64) NOT NULL,
+		event_seq BIGINT NOT NULL,
+		transaction_id UUID NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP,
+		last_attempt_at TIMESTAMP,
+		response_status INTEGER,
+		last_error TEXT,
+		redelivery_of UUID,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		delivered_at TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at);
+	`
+	_, err = conn.Exec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	// timestamps are stored as UTC; CURRENT_TIMESTAMP would follow the session time zone
+	query = `
+		ALTER TABLE transactions ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+		ALTER TABLE imports ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+		ALTER TABLE transaction_events ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+		ALTER TABLE webhooks ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+		ALTER TABLE webhook_deliveries ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+	`
+	_, err = conn.Exec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return migrateRollups(ctx, conn)
+}
+
+// migrateRollups recreates the rollup tables and fills them from the transactions unless they
+// are already at rollupVersion. Instances starting together wait on an advisory lock, so the
+// rollups are rebuilt once; writes to transactions, which update the rollups, wait on a lock of
+// the transactions until it is done.
+func migrateRollups(ctx context.Context, conn DB) error {
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	query := `
+		SELECT pg_advisory_xact_lock(hashtext('transaction_stats_version'));
+
+		CREATE TABLE IF NOT EXISTS transaction_stats_version (
+		version INT NOT NULL
+		);
+	`
+	if _, err = tx.Exec(ctx, query); err != nil {
+		return err
+	}
+
+	var version int
+	err = tx.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM transaction_stats_version`).Scan(&version)
+	if err != nil {
+		return err
+	}
+	if version == rollupVersion {
+		return tx.Commit(ctx)
+	}
+
+	// taken before the rollups, in the order writers take them
+	if _, err = tx.Exec(ctx, `LOCK TABLE transactions IN SHARE MODE`); err != nil {
+		return err
+	}
+
+	query = `
+		DROP TABLE IF EXISTS transaction_stats_hourly, transaction_stats_daily, transaction_stats_users;
+
+		CREATE TABLE transaction_stats_hourly (
+		bucket TIMESTAMP NOT NULL,
+		currency VARCHAR(255) NOT NULL,
+		shard SMALLINT NOT NULL,
+		transactions BIGINT NOT NULL,
+		volume DOUBLE PRECISION NOT NULL,
+		min_amount DOUBLE PRECISION,
+		max_amount DOUBLE PRECISION,
+		succeeded BIGINT NOT NULL,
+		failed BIGINT NOT NULL,
+		unpublished BIGINT NOT NULL,
+		processed BIGINT NOT NULL,
+		processing_seconds DOUBLE PRECISION NOT NULL,
+		processing_max DOUBLE PRECISION,
+		processing_histogram BIGINT[] NOT NULL,
+		PRIMARY KEY (bucket, currency, shard)
+		);
+
+		CREATE TABLE transaction_stats_daily (LIKE transaction_stats_hourly INCLUDING ALL);
+
+		CREATE TABLE transaction_stats_users (
+		bucket TIMESTAMP NOT NULL,
+		currency VARCHAR(255) NOT NULL,
+		user_id VARCHAR(255) NOT NULL,
+		PRIMARY KEY (bucket, currency, user_id)
+		);
+	`
+	if _, err = tx.Exec(ctx, query); err != nil {
+		return err
+	}
+	if err = rebuildRollups(ctx, tx); err != nil {
+		return err
+	}
+
+	query = `
+		DELETE FROM transaction_stats_version;
+		INSERT INTO transaction_stats_version (version) VALUES (` + strconv.Itoa(rollupVersion) + `);
+	`
+	if _, err = tx.Exec(ctx, query); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
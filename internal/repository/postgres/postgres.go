@@ -3,69 +3,186 @@ package postgres
 import (
 	"TransactiStream/internal/domain"
 	"TransactiStream/internal/logger"
+	"TransactiStream/internal/metrics"
 	"context"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"strconv"
 	"time"
 )
 
+// DB is the subset of pgx shared by *pgx.Conn and *pgxpool.Pool.
+type DB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+}
+
+// transactionColumns are read by scanTransaction.
+const transactionColumns = `id, user_id, amount, currency, done, created_at, processed_at`
+
+// exportFetchSize is the number of rows fetched from the export cursor at a time.
+const exportFetchSize = 1000
+
 type Postgres struct {
-	db *pgx.Conn
+	db DB
 }
 
-func NewPostgres(db *pgx.Conn) *Postgres {
+func NewPostgres(db DB) *Postgres {
 	return &Postgres{
 		db: db,
 	}
 }
 
-func (p *Postgres) Create(ctx context.Context, trans *domain.Transaction) (string, error) {
-	var id string
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.db.Ping(ctx)
+}
+
+func (p *Postgres) Create(ctx context.Context, trans *domain.Transaction) (id string, err error) {
+	defer metrics.ObserveQuery("Create", time.Now(), &err)
 
+	// TIMESTAMP columns keep the wall clock of the value, so everything is stored as UTC
 	if trans.Timestamp.IsZero() {
 		trans.Timestamp = time.Now()
 	}
+	trans.Timestamp = trans.Timestamp.UTC()
 
-	err := p.db.QueryRow(ctx, `INSERT INTO transactions (user_id, amount, currency, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return "", mapError(err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	err = tx.QueryRow(ctx, `INSERT INTO transactions (user_id, amount, currency, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
 		trans.UserID, trans.Amount, trans.Currency, trans.Timestamp).Scan(&id)
 	if err != nil {
-		return "", err
+		return "", mapError(err)
+	}
+	if err = updateRollups(ctx, tx, []string{id}, 1); err != nil {
+		return "", mapError(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return "", mapError(err)
 	}
 
 	trans.ID = id
-	logger.Infof("Repo: transaction created: %v", *trans)
+	trans.Status = domain.StatusPending
+	logger.FromContext(ctx).Debug().Object("transaction", trans).Msg("Repo: transaction created")
 
 	return id, nil
 }
 
-func (p *Postgres) Read(ctx context.Context, id string) (*domain.Transaction, error) {
-	trans := &domain.Transaction{}
+// CreateBatch stores all transactions with a single COPY, assigning their IDs; either all rows are stored or none.
+// Processing results already set on a transaction, as in imported history, are stored as well.
+func (p *Postgres) CreateBatch(ctx context.Context, transactions []*domain.Transaction) (err error) {
+	defer metrics.ObserveQuery("CreateBatch", time.Now(), &err)
+
+	now := time.Now().UTC()
+	rows := make([][]any, 0, len(transactions))
+	ids := make([]string, 0, len(transactions))
+	for _, trans := range transactions {
+		if trans.Timestamp.IsZero() {
+			trans.Timestamp = now
+		}
+		trans.Timestamp = trans.Timestamp.UTC()
+		trans.ProcessedAt = utc(trans.ProcessedAt)
+		id := uuid.New()
+		trans.ID = id.String()
+		ids = append(ids, trans.ID)
+
+		processingTime := pgtype.Interval{}
+		if trans.ProcessedAt != nil {
+			processingTime = pgtype.Interval{Microseconds: trans.ProcessedAt.Sub(trans.Timestamp).Microseconds(), Valid: true}
+		}
+		rows = append(rows, []any{id, trans.UserID, trans.Amount, trans.Currency, trans.Done, trans.Timestamp, trans.ProcessedAt, processingTime})
+	}
+
+	if err = p.copyTransactions(ctx, rows, ids); err != nil {
+		for _, trans := range transactions {
+			trans.ID = ""
+		}
+		return err
+	}
 
-	err := p.db.QueryRow(ctx, `SELECT user_id, amount, currency, created_at FROM transactions WHERE id = $1`, id).
-		Scan(&trans.UserID, &trans.Amount, &trans.Currency, &trans.Timestamp)
+	for _, trans := range transactions {
+		trans.Status = domain.StatusOf(trans.Done, trans.ProcessedAt)
+	}
+	logger.FromContext(ctx).Debug().Int("count", len(transactions)).Msg("Repo: transactions created")
+
+	return nil
+}
+
+// copyTransactions stores rows with COPY and adds them, known by ids, to the rollups.
+func (p *Postgres) copyTransactions(ctx context.Context, rows [][]any, ids []string) error {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
-		return nil, err
+		return mapError(err)
 	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
 
-	logger.Infof("Repo: transaction readed: %v", *trans)
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"transactions"},
+		[]string{"id", "user_id", "amount", "currency", "done", "created_at", "processed_at", "processing_time"},
+		pgx.CopyFromRows(rows))
+	if err != nil {
+		return mapError(err)
+	}
+	if err = updateRollups(ctx, tx, ids, 1); err != nil {
+		return mapError(err)
+	}
+
+	return mapError(tx.Commit(ctx))
+}
+
+func (p *Postgres) Read(ctx context.Context, id string) (_ *domain.Transaction, err error) {
+	defer metrics.ObserveQuery("Read", time.Now(), &err)
+
+	trans, err := scanTransaction(p.db.QueryRow(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE id = $1`, id))
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	logger.FromContext(ctx).Debug().Object("transaction", trans).Msg("Repo: transaction read")
 
 	return trans, nil
 }
 
-func (p *Postgres) Update(ctx context.Context, trans *domain.Transaction) error {
-	_, err := p.db.Exec(ctx, `UPDATE transactions SET user_id = $1, amount = $2, currency = $3, done=$4, created_at = $5 WHERE id = $6`,
-		trans.UserID, trans.Amount, trans.Currency, trans.Done, trans.Timestamp, trans.ID)
+func (p *Postgres) Update(ctx context.Context, trans *domain.Transaction) (err error) {
+	defer metrics.ObserveQuery("Update", time.Now(), &err)
+
+	err = p.changeRows(ctx, []string{trans.ID}, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `UPDATE transactions SET user_id = $1, amount = $2, currency = $3, done=$4, created_at = $5 WHERE id = $6`,
+			trans.UserID, trans.Amount, trans.Currency, trans.Done, trans.Timestamp.UTC(), trans.ID)
+		if err != nil {
+			return mapError(err)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("%w: transaction %s", domain.ErrNotFound, trans.ID)
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	logger.Infof("Repo: transaction updated: %v", *trans)
+	logger.FromContext(ctx).Debug().Object("transaction", trans).Msg("Repo: transaction updated")
 
 	return nil
 }
 
-func (p *Postgres) SetProcessedAt(ctx context.Context, id string) error {
-	processedAt := time.Now()
+// SetProcessedAt stores when a transaction was processed, appends its result to the event log and
+// enqueues the webhook deliveries of the event in one database transaction, so every stored result
+// has its event and deliveries.
+func (p *Postgres) SetProcessedAt(ctx context.Context, id string) (ev *domain.TransactionEvent, err error) {
+	defer metrics.ObserveQuery("SetProcessedAt", time.Now(), &err)
+
+	processedAt := time.Now().UTC()
+	deliveries := 0
 
 	query := `
     UPDATE transactions 
@@ -74,81 +191,142 @@ func (p *Postgres) SetProcessedAt(ctx context.Context, id string) error {
         processing_time = $1 - created_at 
     WHERE id = $2`
 
-	_, err := p.db.Exec(ctx, query, processedAt, id)
+	err = p.changeRows(ctx, []string{id}, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, query, processedAt, id); err != nil {
+			return mapError(err)
+		}
+		if ev, err = recordStatusChange(ctx, tx, id); err != nil {
+			return err
+		}
+		if ev.EventType() != "" {
+			deliveries, err = enqueueDeliveries(ctx, tx, ev)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if deliveries > 0 {
+		logger.FromContext(ctx).Debug().Int("count", deliveries).Msg("Repo: webhook deliveries enqueued")
+	}
+
+	return ev, nil
+}
+
+// MarkUnpublished records that the transactions with ids were stored but could not be published,
+// so statistics count them as unpublished rather than pending.
+func (p *Postgres) MarkUnpublished(ctx context.Context, ids []string) (err error) {
+	defer metrics.ObserveQuery("MarkUnpublished", time.Now(), &err)
+
+	err = p.changeRows(ctx, ids, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `UPDATE transactions SET publish_failed = TRUE WHERE id = ANY($1::uuid[])`, ids)
+		return mapError(err)
+	})
 	if err != nil {
 		return err
 	}
 
+	logger.FromContext(ctx).Debug().Int("count", len(ids)).Msg("Repo: transactions marked unpublished")
+
 	return nil
 }
 
-func (p *Postgres) ReadAll(ctx context.Context) ([]*domain.Transaction, error) {
+func (p *Postgres) ReadAll(ctx context.Context, filter domain.TransactionFilter) (_ []*domain.Transaction, err error) {
+	defer metrics.ObserveQuery("ReadAll", time.Now(), &err)
+
 	var transactions []*domain.Transaction
 
-	rows, err := p.db.Query(ctx, `SELECT id, user_id, amount, currency, done, created_at FROM transactions`)
+	where, args := filterSQL(filter)
+	rows, err := p.db.Query(ctx, `SELECT `+transactionColumns+` FROM transactions`+where, args...)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		trans := &domain.Transaction{}
-		err = rows.Scan(&trans.ID, &trans.UserID, &trans.Amount, &trans.Currency, &trans.Done, &trans.Timestamp)
+		trans, err := scanTransaction(rows)
 		if err != nil {
-			return nil, err
+			return nil, mapError(err)
 		}
 		transactions = append(transactions, trans)
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
 	return transactions, nil
 }
 
-func (p *Postgres) GetStatistics(ctx context.Context) (*domain.Statistics, error) {
-	stats := &domain.Statistics{}
-
-	query := `SELECT COUNT(*) FROM transactions`
-	if err := p.db.QueryRow(ctx, query).Scan(&stats.TotalTransactions); err != nil {
-		return nil, fmt.Errorf("failed to get total transactions: %w", err)
-	}
+// Export calls fn for every transaction matching filter in creation order. Rows are read
+// through a server-side cursor, exportFetchSize at a time, so the result is never held in memory.
+func (p *Postgres) Export(ctx context.Context, filter domain.TransactionFilter, fn func(*domain.Transaction) error) (err error) {
+	defer metrics.ObserveQuery("Export", time.Now(), &err)
 
-	// number of failed transactions (done == false)
-	query = `SELECT COUNT(*) FROM transactions WHERE done = FALSE`
-	if err := p.db.QueryRow(ctx, query).Scan(&stats.FailedTransactions); err != nil {
-		return nil, fmt.Errorf("failed to get failed transactions: %w", err)
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return mapError(err)
 	}
+	// the transaction is read-only, rolling it back also closes the cursor
+	defer tx.Rollback(context.WithoutCancel(ctx))
 
-	// number of users
-	query = `SELECT COUNT(DISTINCT user_id) FROM transactions`
-	if err := p.db.QueryRow(ctx, query).Scan(&stats.TotalUsers); err != nil {
-		return nil, fmt.Errorf("failed to get total users: %w", err)
+	where, args := filterSQL(filter)
+	_, err = tx.Exec(ctx, `DECLARE export_cursor NO SCROLL CURSOR FOR SELECT `+transactionColumns+
+		` FROM transactions`+where+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return mapError(err)
 	}
 
-	query = `SELECT AVG(EXTRACT(EPOCH FROM processing_time)) FROM transactions WHERE processing_time IS NOT NULL`
-	var avgProcessingTime float64
-	if err := p.db.QueryRow(ctx, query).Scan(&avgProcessingTime); err != nil {
-		return nil, fmt.Errorf("failed to get average processing time: %w", err)
+	for {
+		n, err := fetchTransactions(ctx, tx, fn)
+		if err != nil {
+			return err
+		}
+		if n < exportFetchSize {
+			return nil
+		}
 	}
-	stats.AverageProcessingTime = avgProcessingTime
+}
 
-	// list of unique currencies (currency)
-	query = `SELECT DISTINCT currency FROM transactions`
-	rows, err := p.db.Query(ctx, query)
+// fetchTransactions passes the next rows of export_cursor to fn and returns how many there were.
+func fetchTransactions(ctx context.Context, tx pgx.Tx, fn func(*domain.Transaction) error) (int, error) {
+	rows, err := tx.Query(ctx, `FETCH FORWARD `+strconv.Itoa(exportFetchSize)+` FROM export_cursor`)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get unique currencies: %w", err)
+		return 0, mapError(err)
 	}
 	defer rows.Close()
 
+	n := 0
 	for rows.Next() {
-		var currency string
-		if err := rows.Scan(&currency); err != nil {
-			return nil, fmt.Errorf("failed to scan currency: %w", err)
+		trans, err := scanTransaction(rows)
+		if err != nil {
+			return n, mapError(err)
 		}
-		stats.Currencies = append(stats.Currencies, currency)
+		if err = fn(trans); err != nil {
+			return n, err
+		}
+		n++
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %w", err)
+	return n, mapError(rows.Err())
+}
+
+func scanTransaction(row pgx.Row) (*domain.Transaction, error) {
+	trans := &domain.Transaction{}
+	err := row.Scan(&trans.ID, &trans.UserID, &trans.Amount, &trans.Currency, &trans.Done, &trans.Timestamp, &trans.ProcessedAt)
+	if err != nil {
+		return nil, err
 	}
+	trans.Status = domain.StatusOf(trans.Done, trans.ProcessedAt)
+	return trans, nil
+}
 
-	return stats, nil
+// utc returns t in UTC, the zone TIMESTAMP columns are stored in; nil stays nil.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
 }
@@ -2,10 +2,12 @@ package postgres
 
 import (
 	"TransactiStream/internal/domain"
+	"TransactiStream/internal/retry"
 	"context"
 	"fmt"
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
 	"log"
@@ -50,7 +52,7 @@ func setupPostgres(t *testing.T) (*pgx.Conn, func()) {
 		t.Fatal(err)
 	}
 
-	err = CreateTables(ctx, *db)
+	err = CreateTables(ctx, db)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -92,6 +94,33 @@ func TestPostgres_Create(t *testing.T) {
 	assert.WithinDuration(t, trans.Timestamp, insertedTransaction.Timestamp, time.Second)
 }
 
+func TestPostgres_StoresUTC(t *testing.T) {
+	db, teardown := setupPostgres(t)
+	defer teardown()
+
+	p := NewPostgres(db)
+	ctx := context.Background()
+
+	moscow := time.FixedZone("MSK", 3*60*60)
+	created := time.Date(2024, 7, 1, 12, 0, 0, 0, moscow)
+	processed := created.Add(5 * time.Second)
+
+	id, err := p.Create(ctx, &domain.Transaction{UserID: "user1", Amount: 1, Currency: "USD", Timestamp: created})
+	assert.NoError(t, err)
+	batch := []*domain.Transaction{{UserID: "user2", Amount: 2, Currency: "USD", Timestamp: created, Done: true, ProcessedAt: &processed}}
+	assert.NoError(t, p.CreateBatch(ctx, batch))
+
+	for _, id := range []string{id, batch[0].ID} {
+		trans, err := p.Read(ctx, id)
+		assert.NoError(t, err)
+		assert.True(t, created.Equal(trans.Timestamp), "created_at %s", trans.Timestamp)
+	}
+
+	stats, err := p.GetStatistics(ctx, domain.StatisticsFilter{From: created.UTC(), To: created.UTC().Add(time.Second)})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalTransactions)
+}
+
 func TestPostgres_Read(t *testing.T) {
 	db, teardown := setupPostgres(t)
 	defer teardown()
@@ -185,7 +214,7 @@ func TestPostgres_ReadAll(t *testing.T) {
 		trans2.UserID, trans2.Amount, trans2.Currency, trans2.Timestamp).Scan(&trans2.ID)
 	assert.NoError(t, err)
 
-	transactions, err := p.ReadAll(context.Background())
+	transactions, err := p.ReadAll(context.Background(), domain.TransactionFilter{})
 	assert.NoError(t, err)
 
 	assert.Equal(t, 2, len(transactions))
@@ -206,3 +235,138 @@ func TestPostgres_ReadAll(t *testing.T) {
 		}
 	}
 }
+
+func TestListener_ReceivesStatusChanges(t *testing.T) {
+	db, teardown := setupPostgres(t)
+	defer teardown()
+
+	p := NewPostgres(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan domain.TransactionEvent, 1)
+	listener := NewListener(db.Config().Copy(), p, retry.Policy{InitialInterval: 100 * time.Millisecond, Multiplier: 1})
+	go listener.Run(ctx, func(ev domain.TransactionEvent) { received <- ev })
+	// LISTEN must be in place before the event is recorded
+	time.Sleep(time.Second)
+
+	trans := &domain.Transaction{UserID: "user1", Amount: 100.0, Currency: "BTC"}
+	id, err := p.Create(ctx, trans)
+	assert.NoError(t, err)
+	ev, err := p.SetProcessedAt(ctx, id)
+	assert.NoError(t, err)
+
+	select {
+	case got := <-received:
+		assert.Equal(t, ev.Seq, got.Seq)
+		assert.Equal(t, id, got.TransactionID)
+		assert.Equal(t, domain.StatusFailed, got.Status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func TestPostgres_ReadEventsInCommitOrder(t *testing.T) {
+	db, teardown := setupPostgres(t)
+	defer teardown()
+
+	p := NewPostgres(db)
+	ctx := context.Background()
+
+	ids := make([]string, 3)
+	for i := range ids {
+		id, err := p.Create(ctx, &domain.Transaction{UserID: "user1", Amount: 10, Currency: "BTC"})
+		require.NoError(t, err)
+		ids[i] = id
+	}
+	first, err := p.SetProcessedAt(ctx, ids[0])
+	require.NoError(t, err)
+
+	// the lower seq commits after the higher one
+	other, err := pgx.ConnectConfig(ctx, db.Config().Copy())
+	require.NoError(t, err)
+	defer other.Close(ctx)
+	tx, err := other.Begin(ctx)
+	require.NoError(t, err)
+	lower, err := recordStatusChange(ctx, tx, ids[1])
+	require.NoError(t, err)
+	higher, err := p.SetProcessedAt(ctx, ids[2])
+	require.NoError(t, err)
+	require.Less(t, lower.Seq, higher.Seq)
+	require.NoError(t, tx.Commit(ctx))
+
+	replay := func(after int64) []int64 {
+		var seqs []int64
+		err := p.ReadEvents(ctx, after, domain.EventFilter{}, func(ev domain.TransactionEvent) error {
+			seqs = append(seqs, ev.Seq)
+			return nil
+		})
+		assert.NoError(t, err)
+		return seqs
+	}
+	assert.Equal(t, []int64{lower.Seq, higher.Seq}, replay(first.Seq))
+	// a client that received the higher seq before the lower one was committed still gets it
+	assert.Equal(t, []int64{lower.Seq}, replay(higher.Seq))
+}
+
+func TestPostgres_StatisticsRollups(t *testing.T) {
+	db, teardown := setupPostgres(t)
+	defer teardown()
+
+	p := NewPostgres(db)
+	ctx := context.Background()
+
+	at := func(d, h, m int) time.Time { return time.Date(2024, 7, d, h, m, 0, 0, time.UTC) }
+	processedAt := at(2, 10, 5)
+	history := []*domain.Transaction{
+		{UserID: "user1", Amount: 10, Currency: "BTC", Timestamp: at(1, 23, 30), Done: true, ProcessedAt: &processedAt},
+		{UserID: "user2", Amount: 20, Currency: "USD", Timestamp: at(2, 10, 0), Done: false, ProcessedAt: &processedAt},
+		{UserID: "user1", Amount: 30, Currency: "USD", Timestamp: at(3, 1, 15)},
+	}
+	assert.NoError(t, p.CreateBatch(ctx, history))
+
+	trans := &domain.Transaction{UserID: "user3", Amount: 40, Currency: "USD", Timestamp: at(3, 2, 45)}
+	_, err := p.Create(ctx, trans)
+	assert.NoError(t, err)
+	assert.NoError(t, p.MarkUnpublished(ctx, []string{history[2].ID}))
+	trans.Done = true
+	assert.NoError(t, p.Update(ctx, trans))
+	_, err = p.SetProcessedAt(ctx, trans.ID)
+	assert.NoError(t, err)
+
+	filters := []domain.StatisticsFilter{
+		{},
+		{From: at(1, 23, 45), To: at(3, 2, 50)},
+		{From: at(1, 0, 0), To: at(4, 0, 0), Interval: domain.IntervalHour},
+		{From: at(1, 0, 0), To: at(4, 0, 0), Interval: domain.IntervalDay, Currency: "USD"},
+		{From: at(2, 9, 30), To: at(2, 10, 30)},
+	}
+	before := make([]*domain.Statistics, len(filters))
+	for i, filter := range filters {
+		before[i], err = p.GetStatistics(ctx, filter)
+		assert.NoError(t, err)
+	}
+
+	stats := before[0]
+	assert.Equal(t, 4, stats.TotalTransactions)
+	assert.Equal(t, 3, stats.TotalUsers)
+	assert.Equal(t, domain.StatusCounts{Succeeded: 2, Failed: 1, Unpublished: 1}, stats.Statuses)
+	assert.Equal(t, []string{"BTC", "USD"}, stats.Currencies)
+	assert.Equal(t, 90.0, stats.ByCurrency[1].Volume)
+	assert.True(t, stats.ProcessingTime.Estimated)
+
+	// a window shorter than an hour is read from the transactions, with exact percentiles
+	assert.Equal(t, domain.ProcessingTimes{P50: 300, P90: 300, P95: 300, P99: 300, Max: 300}, before[4].ProcessingTime)
+
+	// the incrementally maintained rollups agree with ones computed from scratch, whether by
+	// RebuildStatistics or by CreateTables on a rollup version change
+	assert.NoError(t, p.RebuildStatistics(ctx))
+	_, err = db.Exec(ctx, `DELETE FROM transaction_stats_version`)
+	assert.NoError(t, err)
+	assert.NoError(t, CreateTables(ctx, db))
+	for i, filter := range filters {
+		after, err := p.GetStatistics(ctx, filter)
+		assert.NoError(t, err)
+		assert.Equal(t, before[i], after, "filter %+v", filter)
+	}
+}
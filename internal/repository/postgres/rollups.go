@@ -0,0 +1,206 @@
+package postgres
+
+import (
+	"TransactiStream/internal/metrics"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rollup tables hold the statistics of the transactions created in an hour or a day, per
+// currency and shard. They are kept in step with every change to a transaction and can be
+// recomputed from the transactions with RebuildStatistics.
+const (
+	hourlyRollup = "transaction_stats_hourly"
+	dailyRollup  = "transaction_stats_daily"
+	// rollupUsers holds the users seen per hour and currency, to count distinct users.
+	rollupUsers = "transaction_stats_users"
+	// rollupVersion is the layout of the rollup tables, histogram bounds included. Bump it on
+	// any change to them: the tables are then recreated and rebuilt by CreateTables.
+	rollupVersion = 2
+	// rollupShards is the number of rows a bucket and currency are split into. Each connection
+	// writes to its own shard, so concurrent writes in one currency don't queue on one row lock;
+	// readers sum the shards.
+	rollupShards = 8
+)
+
+// rollupUnits maps the rollup tables to the date_trunc field of their buckets.
+var rollupUnits = map[string]string{
+	hourlyRollup: "hour",
+	dailyRollup:  "day",
+}
+
+// processingTimeBounds are the upper bounds in seconds of the processing time histogram, ten
+// per decade from 1 ms to 100000 s, so a bin is at most 26% wider than its lower bound; the last
+// bin has no upper bound. Changing them requires bumping rollupVersion.
+var processingTimeBounds = func() []float64 {
+	bounds := make([]float64, 81)
+	for i := range bounds {
+		bounds[i] = math.Pow(10, float64(i)/10-3)
+	}
+	return bounds
+}()
+
+// rollupColumns are the columns of a rollup table after bucket and currency, in the order of rollupSQL.
+const rollupColumns = `transactions, volume, min_amount, max_amount, succeeded, failed, unpublished,
+	processed, processing_seconds, processing_max, processing_histogram`
+
+// rollupAdd and rollupSubtract aggregate a group of transactions into rollupColumns, to add the
+// group to a rollup or to take it out.
+var (
+	rollupAdd      = rollupSQL(1)
+	rollupSubtract = rollupSQL(-1)
+)
+
+// rollupSQL aggregates a group of transactions into rollupColumns, with the counters and sums
+// multiplied by sign: 1 adds the group to a rollup and -1 takes it out. The minimum and maximum
+// can't be taken out; they are left as they are.
+func rollupSQL(sign int) string {
+	s := strconv.Itoa(sign) + " * "
+
+	bins := make([]string, len(processingTimeBounds)+1)
+	for i := range bins {
+		bins[i] = fmt.Sprintf("%sCOUNT(*) FILTER (WHERE width_bucket(EXTRACT(EPOCH FROM processing_time)::float8, %s) = %d)",
+			s, processingTimeBoundsSQL, i)
+	}
+
+	return s + `COUNT(*), ` + s + `COALESCE(SUM(amount), 0), MIN(amount), MAX(amount),
+		` + s + `COUNT(*) FILTER (WHERE processed_at IS NOT NULL AND done),
+		` + s + `COUNT(*) FILTER (WHERE processed_at IS NOT NULL AND NOT done),
+		` + s + `COUNT(*) FILTER (WHERE processed_at IS NULL AND publish_failed),
+		` + s + `COUNT(processing_time), ` + s + `COALESCE(SUM(EXTRACT(EPOCH FROM processing_time)), 0)::float8,
+		MAX(EXTRACT(EPOCH FROM processing_time))::float8,
+		ARRAY[` + strings.Join(bins, ",\n\t\t") + `]::bigint[]`
+}
+
+// processingTimeBoundsSQL is processingTimeBounds as an array literal for width_bucket.
+var processingTimeBoundsSQL = func() string {
+	bounds := make([]string, len(processingTimeBounds))
+	for i, b := range processingTimeBounds {
+		bounds[i] = strconv.FormatFloat(b, 'f', -1, 64)
+	}
+	return "'{" + strings.Join(bounds, ",") + "}'::float8[]"
+}()
+
+// updateRollups adds the transactions with ids to the rollups, or takes them out with sign -1.
+// Users are only ever added; a user left behind by a change is dropped by RebuildStatistics.
+func updateRollups(ctx context.Context, tx pgx.Tx, ids []string, sign int) error {
+	aggregates := rollupAdd
+	if sign < 0 {
+		aggregates = rollupSubtract
+	}
+
+	for _, table := range []string{hourlyRollup, dailyRollup} {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO `+table+` AS s (bucket, currency, shard, `+rollupColumns+`)
+			SELECT date_trunc('`+rollupUnits[table]+`', created_at), currency, pg_backend_pid() % `+strconv.Itoa(rollupShards)+`,
+				`+aggregates+`
+			FROM transactions WHERE id = ANY($1::uuid[])
+			GROUP BY 1, 2 ORDER BY 1, 2
+			ON CONFLICT (bucket, currency, shard) DO UPDATE SET
+				transactions = s.transactions + EXCLUDED.transactions,
+				volume = s.volume + EXCLUDED.volume,
+				min_amount = LEAST(s.min_amount, EXCLUDED.min_amount),
+				max_amount = GREATEST(s.max_amount, EXCLUDED.max_amount),
+				succeeded = s.succeeded + EXCLUDED.succeeded,
+				failed = s.failed + EXCLUDED.failed,
+				unpublished = s.unpublished + EXCLUDED.unpublished,
+				processed = s.processed + EXCLUDED.processed,
+				processing_seconds = s.processing_seconds + EXCLUDED.processing_seconds,
+				processing_max = GREATEST(s.processing_max, EXCLUDED.processing_max),
+				processing_histogram = ARRAY(
+					SELECT a + b FROM unnest(s.processing_histogram, EXCLUDED.processing_histogram)
+					WITH ORDINALITY AS h(a, b, i) ORDER BY i)`, ids)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", table, err)
+		}
+	}
+
+	if sign > 0 {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO `+rollupUsers+` (bucket, currency, user_id)
+			SELECT DISTINCT date_trunc('hour', created_at), currency, user_id
+			FROM transactions WHERE id = ANY($1::uuid[])
+			ORDER BY 1, 2, 3
+			ON CONFLICT DO NOTHING`, ids)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", rollupUsers, err)
+		}
+	}
+
+	return nil
+}
+
+// changeRows runs change on the transactions with ids in a database transaction, keeping the
+// rollups in step: the rows are locked and taken out of the rollups before change and added
+// back after it.
+func (p *Postgres) changeRows(ctx context.Context, ids []string, change func(tx pgx.Tx) error) error {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return mapError(err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	if _, err = tx.Exec(ctx, `SELECT 1 FROM transactions WHERE id = ANY($1::uuid[]) ORDER BY id FOR UPDATE`, ids); err != nil {
+		return mapError(err)
+	}
+	if err = updateRollups(ctx, tx, ids, -1); err != nil {
+		return mapError(err)
+	}
+	if err = change(tx); err != nil {
+		return err
+	}
+	if err = updateRollups(ctx, tx, ids, 1); err != nil {
+		return mapError(err)
+	}
+
+	return mapError(tx.Commit(ctx))
+}
+
+// RebuildStatistics recomputes the rollups from the transactions. Writes to transactions wait
+// until it is done, so no change is missed.
+func (p *Postgres) RebuildStatistics(ctx context.Context) (err error) {
+	defer metrics.ObserveQuery("RebuildStatistics", time.Now(), &err)
+
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return mapError(err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	if err = rebuildRollups(ctx, tx); err != nil {
+		return mapError(err)
+	}
+
+	return mapError(tx.Commit(ctx))
+}
+
+// rebuildRollups replaces the rollups with ones computed from the transactions, all in the
+// first shard. Transactions are locked before the rollups, in the order writers take them.
+func rebuildRollups(ctx context.Context, tx pgx.Tx) error {
+	queries := []string{
+		`LOCK TABLE transactions IN SHARE MODE`,
+		`TRUNCATE ` + hourlyRollup + `, ` + dailyRollup + `, ` + rollupUsers,
+	}
+	for _, table := range []string{hourlyRollup, dailyRollup} {
+		queries = append(queries, `
+			INSERT INTO `+table+` (bucket, currency, shard, `+rollupColumns+`)
+			SELECT date_trunc('`+rollupUnits[table]+`', created_at), currency, 0, `+rollupAdd+`
+			FROM transactions GROUP BY 1, 2`)
+	}
+	queries = append(queries, `
+		INSERT INTO `+rollupUsers+` (bucket, currency, user_id)
+		SELECT DISTINCT date_trunc('hour', created_at), currency, user_id FROM transactions`)
+
+	for _, query := range queries {
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return fmt.Errorf("failed to rebuild statistics: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,564 @@
+package postgres
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/metrics"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// GetStatistics aggregates the transactions created in the window of filter, per bucket as well
+// when filter has an interval. Whole hours and days are read from the rollups; the transactions
+// themselves are only read for the rest of the window and for those still unprocessed.
+func (p *Postgres) GetStatistics(ctx context.Context, filter domain.StatisticsFilter) (_ *domain.Statistics, err error) {
+	defer metrics.ObserveQuery("GetStatistics", time.Now(), &err)
+
+	// a snapshot, so the rollups and the transactions read agree with each other
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	segments := planSegments(filter.From, filter.To, filter.Interval)
+	unit := string(filter.Interval)
+	if unit == "" {
+		unit = "hour"
+	}
+
+	var rows []rollupRow
+	for _, seg := range segments {
+		segRows, err := readSegment(ctx, tx, seg, filter.Currency, unit)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, segRows...)
+	}
+
+	unprocessed, err := readUnprocessed(ctx, tx, filter, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &domain.Statistics{}
+	if stats.TotalUsers, err = countUsers(ctx, tx, segments, filter.Currency); err != nil {
+		return nil, err
+	}
+
+	var (
+		total      = &aggregate{}
+		byCurrency = map[string]*aggregate{}
+		byStart    = map[time.Time]*aggregate{}
+		step       = filter.Interval.Duration()
+	)
+	groups := func(start time.Time, currency string) []*aggregate {
+		aggs := []*aggregate{total, groupOf(byCurrency, currency)}
+		if step > 0 {
+			aggs = append(aggs, groupOf(byStart, start.Truncate(step)))
+		}
+		return aggs
+	}
+	for i := range rows {
+		for _, a := range groups(rows[i].start, rows[i].currency) {
+			a.add(&rows[i])
+		}
+	}
+	for _, u := range unprocessed {
+		for _, a := range groups(u.start, u.currency) {
+			a.statuses.Pending += u.pending
+			a.statuses.TimedOut += u.timedOut
+		}
+	}
+
+	// a window read from the transactions alone doesn't need the histogram estimates
+	if len(segments) == 1 && segments[0].table == "" {
+		percentiles, err := readPercentiles(ctx, tx, filter, unit)
+		if err != nil {
+			return nil, err
+		}
+		for _, pr := range percentiles {
+			switch {
+			case pr.currency != nil:
+				groupOf(byCurrency, *pr.currency).percentiles = pr.values
+			case pr.start != nil:
+				if step > 0 {
+					groupOf(byStart, pr.start.Truncate(step)).percentiles = pr.values
+				}
+			default:
+				total.percentiles = pr.values
+			}
+		}
+	}
+
+	stats.TotalTransactions = total.transactions
+	stats.Statuses = total.statuses
+	stats.FailedTransactions = total.statuses.Failed
+	stats.SuccessRate = total.statuses.SuccessRate()
+	stats.AverageProcessingTime = total.averageProcessingTime()
+	stats.ProcessingTime = total.processingTimes()
+
+	currencies := make([]string, 0, len(byCurrency))
+	for currency, a := range byCurrency {
+		if a.transactions > 0 {
+			currencies = append(currencies, currency)
+		}
+	}
+	sort.Strings(currencies)
+	stats.ByCurrency = make([]domain.CurrencyStatistics, 0, len(currencies))
+	for _, currency := range currencies {
+		a := byCurrency[currency]
+		stats.Currencies = append(stats.Currencies, currency)
+		stats.ByCurrency = append(stats.ByCurrency, domain.CurrencyStatistics{
+			Currency:              currency,
+			Transactions:          a.transactions,
+			Volume:                a.volume,
+			AverageAmount:         a.volume / float64(a.transactions),
+			MinAmount:             valueOr(a.minAmount, 0),
+			MaxAmount:             valueOr(a.maxAmount, 0),
+			StatusCounts:          a.statuses,
+			SuccessRate:           a.statuses.SuccessRate(),
+			AverageProcessingTime: a.averageProcessingTime(),
+			ProcessingTime:        a.processingTimes(),
+		})
+	}
+
+	if step > 0 {
+		sparse := make([]domain.StatisticsBucket, 0, len(byStart))
+		for start, a := range byStart {
+			sparse = append(sparse, domain.StatisticsBucket{
+				Start:                 start,
+				Transactions:          a.transactions,
+				StatusCounts:          a.statuses,
+				SuccessRate:           a.statuses.SuccessRate(),
+				Volume:                a.volume,
+				AverageProcessingTime: a.averageProcessingTime(),
+				ProcessingTime:        a.processingTimes(),
+			})
+		}
+		sort.Slice(sparse, func(i, j int) bool { return sparse[i].Start.Before(sparse[j].Start) })
+		stats.Series = fillSeries(sparse, filter)
+	}
+
+	return stats, nil
+}
+
+// segment is a part of a statistics window read from a rollup table, or from the transactions
+// when table is empty. Zero bounds are open.
+type segment struct {
+	table    string
+	from, to time.Time
+}
+
+// planSegments splits the window [from, to) by where it is read from: whole days from the daily
+// rollup unless the series is hourly, whole hours from the hourly rollup, and what is left, or
+// everything for a minute series, from the transactions. Zero bounds are open.
+func planSegments(from, to time.Time, interval domain.Interval) []segment {
+	if interval == domain.IntervalMinute {
+		return []segment{{from: from, to: to}}
+	}
+
+	// the zero time is aligned to both, so open bounds stay open
+	hourFrom, hourTo := ceilTime(from, time.Hour), to.Truncate(time.Hour)
+	if !from.IsZero() && !to.IsZero() && !hourFrom.Before(hourTo) {
+		return []segment{{from: from, to: to}}
+	}
+
+	var segments []segment
+	if !from.Equal(hourFrom) {
+		segments = append(segments, segment{from: from, to: hourFrom})
+	}
+
+	dayFrom, dayTo := ceilTime(hourFrom, day), hourTo.Truncate(day)
+	switch {
+	case interval == domain.IntervalHour,
+		!hourFrom.IsZero() && !hourTo.IsZero() && !dayFrom.Before(dayTo):
+		segments = append(segments, segment{table: hourlyRollup, from: hourFrom, to: hourTo})
+	default:
+		if !hourFrom.Equal(dayFrom) {
+			segments = append(segments, segment{table: hourlyRollup, from: hourFrom, to: dayFrom})
+		}
+		segments = append(segments, segment{table: dailyRollup, from: dayFrom, to: dayTo})
+		if !dayTo.Equal(hourTo) {
+			segments = append(segments, segment{table: hourlyRollup, from: dayTo, to: hourTo})
+		}
+	}
+
+	if !to.Equal(hourTo) {
+		segments = append(segments, segment{from: hourTo, to: to})
+	}
+
+	return segments
+}
+
+// ceilTime rounds t up to a multiple of d.
+func ceilTime(t time.Time, d time.Duration) time.Time {
+	if down := t.Truncate(d); !down.Equal(t) {
+		return down.Add(d)
+	}
+	return t
+}
+
+// rangeSQL renders the conditions on currency and on column being in [from, to) as a WHERE
+// clause, adding their values to args. Zero values match everything.
+func rangeSQL(column, currency string, from, to time.Time, args *[]any) string {
+	var conds []string
+	arg := func(v any) string {
+		*args = append(*args, v)
+		return "$" + strconv.Itoa(len(*args))
+	}
+
+	if currency != "" {
+		conds = append(conds, "currency = "+arg(currency))
+	}
+	if !from.IsZero() {
+		conds = append(conds, column+" >= "+arg(from))
+	}
+	if !to.IsZero() {
+		conds = append(conds, column+" < "+arg(to))
+	}
+
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// rollupRow is a row of a rollup table, or the same aggregates computed from transactions.
+type rollupRow struct {
+	start                                                   time.Time
+	currency                                                string
+	transactions, succeeded, failed, unpublished, processed int
+	volume, processingSeconds                               float64
+	minAmount, maxAmount, processingMax                     *float64
+	histogram                                               []int64
+}
+
+// readSegment reads the rollup rows of seg, aggregating transactions per unit when seg has no table.
+func readSegment(ctx context.Context, tx pgx.Tx, seg segment, currency, unit string) ([]rollupRow, error) {
+	var (
+		args  []any
+		query string
+	)
+	if seg.table != "" {
+		query = `SELECT bucket, currency, ` + rollupColumns + ` FROM ` + seg.table +
+			rangeSQL("bucket", currency, seg.from, seg.to, &args)
+	} else {
+		args = append(args, unit)
+		query = `SELECT date_trunc($1, created_at), currency, ` + rollupAdd + ` FROM transactions` +
+			rangeSQL("created_at", currency, seg.from, seg.to, &args) + ` GROUP BY 1, 2`
+	}
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read statistics: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	var result []rollupRow
+	for rows.Next() {
+		var r rollupRow
+		err = rows.Scan(&r.start, &r.currency, &r.transactions, &r.volume, &r.minAmount, &r.maxAmount,
+			&r.succeeded, &r.failed, &r.unpublished, &r.processed, &r.processingSeconds, &r.processingMax, &r.histogram)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan statistics: %w", mapError(err))
+		}
+		result = append(result, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", mapError(err))
+	}
+
+	return result, nil
+}
+
+// unprocessedRow counts the published transactions without a result created in a unit and currency.
+type unprocessedRow struct {
+	start             time.Time
+	currency          string
+	pending, timedOut int
+}
+
+// readUnprocessed counts the unprocessed transactions in the window of filter, which are pending
+// or timed out depending on the time of the request and so can't be rolled up.
+func readUnprocessed(ctx context.Context, tx pgx.Tx, filter domain.StatisticsFilter, unit string) ([]unprocessedRow, error) {
+	args := []any{unit, filter.TimedOutBefore}
+	where := rangeSQL("created_at", filter.Currency, filter.From, filter.To, &args)
+	if where == "" {
+		where = " WHERE "
+	} else {
+		where += " AND "
+	}
+
+	// the zero TimedOutBefore is older than any row, so nothing is timed out
+	rows, err := tx.Query(ctx, `
+		SELECT date_trunc($1, created_at), currency,
+			COUNT(*) FILTER (WHERE created_at >= $2), COUNT(*) FILTER (WHERE created_at < $2)
+		FROM transactions`+where+`processed_at IS NULL AND NOT publish_failed
+		GROUP BY 1, 2`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unprocessed transactions: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	var result []unprocessedRow
+	for rows.Next() {
+		var u unprocessedRow
+		if err = rows.Scan(&u.start, &u.currency, &u.pending, &u.timedOut); err != nil {
+			return nil, fmt.Errorf("failed to scan unprocessed transactions: %w", mapError(err))
+		}
+		result = append(result, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", mapError(err))
+	}
+
+	return result, nil
+}
+
+// percentilesRow holds the exact processing time percentiles of all the transactions, of those
+// in a currency or of those created in a unit, depending on which of currency and start is set.
+type percentilesRow struct {
+	start    *time.Time
+	currency *string
+	values   []float64
+}
+
+// readPercentiles computes the processing time percentiles of the transactions in the window of
+// filter with percentile_cont, in total, per currency and per unit, in the order of processingTimes.
+func readPercentiles(ctx context.Context, tx pgx.Tx, filter domain.StatisticsFilter, unit string) ([]percentilesRow, error) {
+	args := []any{unit}
+	where := rangeSQL("created_at", filter.Currency, filter.From, filter.To, &args)
+	if where == "" {
+		where = " WHERE "
+	} else {
+		where += " AND "
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT start, currency, percentile_cont('{0.5,0.9,0.95,0.99}'::float8[]) WITHIN GROUP (ORDER BY seconds)
+		FROM (
+			SELECT date_trunc($1, created_at) AS start, currency, EXTRACT(EPOCH FROM processing_time)::float8 AS seconds
+			FROM transactions`+where+`processing_time IS NOT NULL
+		) t
+		GROUP BY GROUPING SETS ((), (currency), (start))`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute processing time percentiles: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	var result []percentilesRow
+	for rows.Next() {
+		var pr percentilesRow
+		if err = rows.Scan(&pr.start, &pr.currency, &pr.values); err != nil {
+			return nil, fmt.Errorf("failed to scan processing time percentiles: %w", mapError(err))
+		}
+		result = append(result, pr)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", mapError(err))
+	}
+
+	return result, nil
+}
+
+// countUsers counts the distinct users in segments, from the users seen per hour for the rollup
+// segments and from the transactions for the others.
+func countUsers(ctx context.Context, tx pgx.Tx, segments []segment, currency string) (int, error) {
+	var (
+		args  []any
+		parts = make([]string, len(segments))
+	)
+	for i, seg := range segments {
+		if seg.table != "" {
+			parts[i] = `SELECT user_id FROM ` + rollupUsers + rangeSQL("bucket", currency, seg.from, seg.to, &args)
+		} else {
+			parts[i] = `SELECT user_id FROM transactions` + rangeSQL("created_at", currency, seg.from, seg.to, &args)
+		}
+	}
+
+	var users int
+	err := tx.QueryRow(ctx, `SELECT COUNT(DISTINCT user_id) FROM (`+strings.Join(parts, ` UNION ALL `)+`) u`, args...).Scan(&users)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", mapError(err))
+	}
+
+	return users, nil
+}
+
+// aggregate merges rollup rows.
+type aggregate struct {
+	transactions         int
+	statuses             domain.StatusCounts
+	volume               float64
+	minAmount, maxAmount *float64
+	processed            int
+	processingSeconds    float64
+	processingMax        *float64
+	histogram            []int64
+	// percentiles are the exact p50, p90, p95 and p99 when they were computed from the transactions.
+	percentiles []float64
+}
+
+func groupOf[K comparable](groups map[K]*aggregate, key K) *aggregate {
+	a, ok := groups[key]
+	if !ok {
+		a = &aggregate{}
+		groups[key] = a
+	}
+	return a
+}
+
+func (a *aggregate) add(r *rollupRow) {
+	a.transactions += r.transactions
+	a.statuses.Succeeded += r.succeeded
+	a.statuses.Failed += r.failed
+	a.statuses.Unpublished += r.unpublished
+	a.volume += r.volume
+	a.processed += r.processed
+	a.processingSeconds += r.processingSeconds
+
+	// the bounds of a row all of whose transactions were taken out are stale
+	if r.transactions > 0 {
+		a.minAmount = mergeFloat(a.minAmount, r.minAmount, math.Min)
+		a.maxAmount = mergeFloat(a.maxAmount, r.maxAmount, math.Max)
+	}
+	if r.processed > 0 {
+		a.processingMax = mergeFloat(a.processingMax, r.processingMax, math.Max)
+	}
+
+	if len(a.histogram) < len(r.histogram) {
+		a.histogram = append(a.histogram, make([]int64, len(r.histogram)-len(a.histogram))...)
+	}
+	for i, n := range r.histogram {
+		a.histogram[i] += n
+	}
+}
+
+func (a *aggregate) averageProcessingTime() float64 {
+	if a.processed == 0 {
+		return 0
+	}
+	return a.processingSeconds / float64(a.processed)
+}
+
+// processingTimes returns the exact percentiles if a has them and estimates them from the
+// histogram otherwise; the maximum is exact.
+func (a *aggregate) processingTimes() domain.ProcessingTimes {
+	if a.processed == 0 {
+		return domain.ProcessingTimes{}
+	}
+
+	maximum := valueOr(a.processingMax, 0)
+	if len(a.percentiles) == 4 {
+		return domain.ProcessingTimes{
+			P50: a.percentiles[0],
+			P90: a.percentiles[1],
+			P95: a.percentiles[2],
+			P99: a.percentiles[3],
+			Max: maximum,
+		}
+	}
+	return domain.ProcessingTimes{
+		P50:       histogramQuantile(a.histogram, 0.5, maximum),
+		P90:       histogramQuantile(a.histogram, 0.9, maximum),
+		P95:       histogramQuantile(a.histogram, 0.95, maximum),
+		P99:       histogramQuantile(a.histogram, 0.99, maximum),
+		Max:       maximum,
+		Estimated: true,
+	}
+}
+
+// histogramQuantile estimates the q-quantile of the processing time histogram, interpolating
+// linearly within the bin it falls in. The last bin, and any bin above maximum, ends at maximum.
+func histogramQuantile(histogram []int64, q, maximum float64) float64 {
+	var count int64
+	for _, n := range histogram {
+		count += max(n, 0)
+	}
+	if count == 0 {
+		return 0
+	}
+
+	rank := q * float64(count)
+	var below float64
+	for i, n := range histogram {
+		if n <= 0 {
+			continue
+		}
+		if below+float64(n) >= rank {
+			lower, upper := 0.0, maximum
+			if i > 0 {
+				lower = processingTimeBounds[i-1]
+			}
+			if i < len(processingTimeBounds) {
+				upper = min(processingTimeBounds[i], maximum)
+			}
+			return lower + (upper-lower)*(rank-below)/float64(n)
+		}
+		below += float64(n)
+	}
+
+	return maximum
+}
+
+// mergeFloat combines a and b with f, either of them may be unset.
+func mergeFloat(a, b *float64, f func(x, y float64) float64) *float64 {
+	switch {
+	case b == nil:
+		return a
+	case a == nil:
+		v := *b
+		return &v
+	}
+	v := f(*a, *b)
+	return &v
+}
+
+func valueOr(v *float64, def float64) float64 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// fillSeries adds the empty buckets missing from sparse, so the series has one bucket per
+// interval from the one containing filter.From up to filter.To. An open bound is taken from
+// the first or last bucket of sparse.
+func fillSeries(sparse []domain.StatisticsBucket, filter domain.StatisticsFilter) []domain.StatisticsBucket {
+	step := filter.Interval.Duration()
+
+	start, end := filter.From.Truncate(step), filter.To
+	if filter.From.IsZero() {
+		if len(sparse) == 0 {
+			return []domain.StatisticsBucket{}
+		}
+		start = sparse[0].Start
+	}
+	if filter.To.IsZero() {
+		if len(sparse) == 0 {
+			return []domain.StatisticsBucket{}
+		}
+		end = sparse[len(sparse)-1].Start.Add(step)
+	}
+
+	series := []domain.StatisticsBucket{}
+	i := 0
+	for t := start; t.Before(end); t = t.Add(step) {
+		if i < len(sparse) && sparse[i].Start.Equal(t) {
+			series = append(series, sparse[i])
+			i++
+			continue
+		}
+		series = append(series, domain.StatisticsBucket{Start: t})
+	}
+
+	return series
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"TransactiStream/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFillSeries(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 7, 31, hour, 0, 0, 0, time.UTC) }
+	sparse := []domain.StatisticsBucket{
+		{Start: at(10), Transactions: 2},
+		{Start: at(12), Transactions: 1},
+	}
+
+	series := fillSeries(sparse, domain.StatisticsFilter{From: at(9).Add(30 * time.Minute), To: at(14), Interval: domain.IntervalHour})
+	starts := make([]int, len(series))
+	counts := make([]int, len(series))
+	for i, b := range series {
+		starts[i], counts[i] = b.Start.Hour(), b.Transactions
+	}
+	assert.Equal(t, []int{9, 10, 11, 12, 13}, starts)
+	assert.Equal(t, []int{0, 2, 0, 1, 0}, counts)
+
+	series = fillSeries(sparse, domain.StatisticsFilter{Interval: domain.IntervalHour})
+	assert.Len(t, series, 3)
+
+	assert.Empty(t, fillSeries(nil, domain.StatisticsFilter{From: at(9), Interval: domain.IntervalHour}))
+	assert.Len(t, fillSeries(nil, domain.StatisticsFilter{From: at(9), To: at(11), Interval: domain.IntervalHour}), 2)
+}
+
+func TestPlanSegments(t *testing.T) {
+	at := func(d, h, m int) time.Time { return time.Date(2024, 7, d, h, m, 0, 0, time.UTC) }
+	raw := func(from, to time.Time) segment { return segment{from: from, to: to} }
+	hourly := func(from, to time.Time) segment { return segment{table: hourlyRollup, from: from, to: to} }
+	daily := func(from, to time.Time) segment { return segment{table: dailyRollup, from: from, to: to} }
+
+	cases := map[string]struct {
+		from, to time.Time
+		interval domain.Interval
+		want     []segment
+	}{
+		"all time": {
+			want: []segment{daily(time.Time{}, time.Time{})},
+		},
+		"days with ragged edges": {
+			from: at(1, 22, 30), to: at(4, 3, 15),
+			want: []segment{
+				raw(at(1, 22, 30), at(1, 23, 0)),
+				hourly(at(1, 23, 0), at(2, 0, 0)),
+				daily(at(2, 0, 0), at(4, 0, 0)),
+				hourly(at(4, 0, 0), at(4, 3, 0)),
+				raw(at(4, 3, 0), at(4, 3, 15)),
+			},
+		},
+		"hourly series": {
+			from: at(1, 0, 0), to: at(4, 0, 0), interval: domain.IntervalHour,
+			want: []segment{hourly(at(1, 0, 0), at(4, 0, 0))},
+		},
+		"less than a day": {
+			from: at(1, 10, 0), to: at(1, 12, 30),
+			want: []segment{hourly(at(1, 10, 0), at(1, 12, 0)), raw(at(1, 12, 0), at(1, 12, 30))},
+		},
+		"within an hour": {
+			from: at(1, 10, 5), to: at(1, 10, 55),
+			want: []segment{raw(at(1, 10, 5), at(1, 10, 55))},
+		},
+		"open end": {
+			from: at(1, 10, 0),
+			want: []segment{hourly(at(1, 10, 0), at(2, 0, 0)), daily(at(2, 0, 0), time.Time{})},
+		},
+		"minute series": {
+			from: at(1, 0, 0), to: at(2, 0, 0), interval: domain.IntervalMinute,
+			want: []segment{raw(at(1, 0, 0), at(2, 0, 0))},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, c.want, planSegments(c.from, c.to, c.interval))
+		})
+	}
+}
+
+func TestAggregate_ProcessingTimes(t *testing.T) {
+	// 90 transactions took lo-mid and 10 took mid-hi
+	lo, mid, hi := processingTimeBounds[29], processingTimeBounds[30], processingTimeBounds[31]
+	bins := func(low, high int64) []int64 {
+		histogram := make([]int64, len(processingTimeBounds)+1)
+		histogram[30], histogram[31] = low, high
+		return histogram
+	}
+	maximum := 1.2
+
+	a := &aggregate{}
+	a.add(&rollupRow{transactions: 60, processed: 60, processingSeconds: 60, processingMax: &maximum, histogram: bins(60, 0)})
+	a.add(&rollupRow{transactions: 40, processed: 40, processingSeconds: 40, histogram: bins(30, 10)})
+
+	times := a.processingTimes()
+	assert.True(t, times.Estimated)
+	assert.InDelta(t, lo+(mid-lo)*50/90.0, times.P50, 1e-9)
+	assert.InDelta(t, mid, times.P90, 1e-9)
+	// the bin of p99 ends at the maximum rather than at its bound hi
+	assert.Less(t, maximum, hi)
+	assert.InDelta(t, mid+(maximum-mid)*0.9, times.P99, 1e-9)
+	assert.Equal(t, 1.2, times.Max)
+	assert.Equal(t, 1.0, a.averageProcessingTime())
+
+	a.percentiles = []float64{0.9, 1.05, 1.1, 1.15}
+	assert.Equal(t, domain.ProcessingTimes{P50: 0.9, P90: 1.05, P95: 1.1, P99: 1.15, Max: 1.2}, a.processingTimes())
+
+	assert.Zero(t, (&aggregate{}).processingTimes())
+}
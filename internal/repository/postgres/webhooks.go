@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/metrics"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// deliveryColumns are scanned into deliveryFields; the deliveries table is always aliased as d.
+const deliveryColumns = `d.id, d.webhook_id, d.event_type, d.event_seq, d.transaction_id, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.last_attempt_at, COALESCE(d.response_status, 0), COALESCE(d.last_error, ''),
+	COALESCE(d.redelivery_of::text, ''), d.created_at, d.delivered_at`
+
+func (p *Postgres) CreateWebhook(ctx context.Context, wh *domain.Webhook) (err error) {
+	defer metrics.ObserveQuery("CreateWebhook", time.Now(), &err)
+
+	err = p.db.QueryRow(ctx, `INSERT INTO webhooks (url, secret, event_types) VALUES ($1, $2, $3) RETURNING id, created_at`,
+		wh.URL, wh.Secret, wh.EventTypes).Scan(&wh.ID, &wh.CreatedAt)
+	if err != nil {
+		return mapError(err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) ListWebhooks(ctx context.Context) (_ []*domain.Webhook, err error) {
+	defer metrics.ObserveQuery("ListWebhooks", time.Now(), &err)
+
+	rows, err := p.db.Query(ctx, `SELECT id, url, event_types, created_at FROM webhooks ORDER BY created_at, id`)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	webhooks := []*domain.Webhook{}
+	for rows.Next() {
+		wh := &domain.Webhook{}
+		if err = rows.Scan(&wh.ID, &wh.URL, &wh.EventTypes, &wh.CreatedAt); err != nil {
+			return nil, mapError(err)
+		}
+		webhooks = append(webhooks, wh)
+	}
+
+	return webhooks, mapError(rows.Err())
+}
+
+// DeleteWebhook removes a webhook together with its deliveries.
+func (p *Postgres) DeleteWebhook(ctx context.Context, id string) (err error) {
+	defer metrics.ObserveQuery("DeleteWebhook", time.Now(), &err)
+
+	tag, err := p.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: webhook %s", domain.ErrNotFound, id)
+	}
+
+	return nil
+}
+
+// enqueueDeliveries creates a pending delivery of ev for every webhook subscribed to its event type
+// and returns how many were created.
+func enqueueDeliveries(ctx context.Context, tx pgx.Tx, ev *domain.TransactionEvent) (int, error) {
+	eventType := ev.EventType()
+	payload, err := json.Marshal(domain.WebhookPayload{Type: eventType, Event: *ev})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_type, event_seq, transaction_id, payload, status, next_attempt_at)
+		SELECT id, $1::text, $2::bigint, $3::uuid, $4::jsonb, $5::text, $6::timestamp FROM webhooks WHERE $1 = ANY(event_types)`,
+		eventType, ev.Seq, ev.TransactionID, payload, domain.DeliveryPending, time.Now().UTC())
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// ClaimDeliveries returns up to limit pending deliveries that are due, together with the URL and
+// secret of their webhooks. Claimed deliveries are not due again until lease passes, so concurrent
+// workers never get the same delivery and one abandoned by a crashed worker is eventually retried.
+func (p *Postgres) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) (_ []*domain.WebhookDelivery, err error) {
+	defer metrics.ObserveQuery("ClaimDeliveries", time.Now(), &err)
+
+	now := time.Now().UTC()
+	rows, err := p.db.Query(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = $1
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $2 AND next_attempt_at <= $3
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED)
+		RETURNING `+deliveryColumns+`, w.url, w.secret`,
+		now.Add(lease), domain.DeliveryPending, now, limit)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		d := &domain.WebhookDelivery{}
+		if err = rows.Scan(append(deliveryFields(d), &d.URL, &d.Secret)...); err != nil {
+			return nil, mapError(err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, mapError(rows.Err())
+}
+
+// CountDueDeliveries counts the pending deliveries that are due, up to limit.
+func (p *Postgres) CountDueDeliveries(ctx context.Context, limit int) (n int, err error) {
+	defer metrics.ObserveQuery("CountDueDeliveries", time.Now(), &err)
+
+	err = p.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT 1 FROM webhook_deliveries WHERE status = $1 AND next_attempt_at <= $2 LIMIT $3) d`,
+		domain.DeliveryPending, time.Now().UTC(), limit).Scan(&n)
+	return n, mapError(err)
+}
+
+// RecordDeliveryAttempt stores the outcome of the last attempt of d.
+func (p *Postgres) RecordDeliveryAttempt(ctx context.Context, d *domain.WebhookDelivery) (err error) {
+	defer metrics.ObserveQuery("RecordDeliveryAttempt", time.Now(), &err)
+
+	tag, err := p.db.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_attempt_at = $4, response_status = NULLIF($5, 0),
+			last_error = NULLIF($6, ''), delivered_at = $7
+		WHERE id = $8`,
+		d.Status, d.Attempts, utc(d.NextAttemptAt), utc(d.LastAttemptAt), d.ResponseStatus, d.LastError, utc(d.DeliveredAt), d.ID)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: delivery %s", domain.ErrNotFound, d.ID)
+	}
+
+	return nil
+}
+
+// ListDeliveries returns the latest deliveries of a webhook, newest first, optionally only those with status.
+func (p *Postgres) ListDeliveries(ctx context.Context, webhookID string, status domain.DeliveryStatus, limit int) (_ []*domain.WebhookDelivery, err error) {
+	defer metrics.ObserveQuery("ListDeliveries", time.Now(), &err)
+
+	// distinguishes an unknown webhook from one without deliveries
+	var exists bool
+	if err = p.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1)`, webhookID).Scan(&exists); err != nil {
+		return nil, mapError(err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: webhook %s", domain.ErrNotFound, webhookID)
+	}
+
+	rows, err := p.db.Query(ctx, `
+		SELECT `+deliveryColumns+` FROM webhook_deliveries d
+		WHERE d.webhook_id = $1 AND ($2 = '' OR d.status = $2)
+		ORDER BY d.created_at DESC, d.id
+		LIMIT $3`, webhookID, status, limit)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	deliveries := []*domain.WebhookDelivery{}
+	for rows.Next() {
+		d := &domain.WebhookDelivery{}
+		if err = rows.Scan(deliveryFields(d)...); err != nil {
+			return nil, mapError(err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, mapError(rows.Err())
+}
+
+// Redeliver queues a new delivery with the payload of an earlier one of the same webhook.
+func (p *Postgres) Redeliver(ctx context.Context, webhookID, deliveryID string) (_ *domain.WebhookDelivery, err error) {
+	defer metrics.ObserveQuery("Redeliver", time.Now(), &err)
+
+	d := &domain.WebhookDelivery{}
+	err = p.db.QueryRow(ctx, `
+		INSERT INTO webhook_deliveries AS d (webhook_id, event_type, event_seq, transaction_id, payload, status, next_attempt_at, redelivery_of)
+		SELECT webhook_id, event_type, event_seq, transaction_id, payload, $1::text, $2::timestamp, id
+		FROM webhook_deliveries WHERE id = $3 AND webhook_id = $4
+		RETURNING `+deliveryColumns,
+		domain.DeliveryPending, time.Now().UTC(), deliveryID, webhookID).Scan(deliveryFields(d)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return d, nil
+}
+
+func deliveryFields(d *domain.WebhookDelivery) []any {
+	return []any{&d.ID, &d.WebhookID, &d.EventType, &d.EventSeq, &d.TransactionID, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastAttemptAt, &d.ResponseStatus, &d.LastError, &d.RedeliveryOf, &d.CreatedAt, &d.DeliveredAt}
+}
@@ -0,0 +1,89 @@
+package retry
+
+import (
+	"TransactiStream/internal/logger"
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Policy describes an exponential backoff with jitter bounded by MaxElapsedTime.
+type Policy struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// Jitter is the fraction (0..1) by which every interval is randomly shifted up or down.
+	Jitter         float64
+	MaxElapsedTime time.Duration
+}
+
+// Interval returns the delay before the given retry (counting from 1) without jitter.
+func (p Policy) Interval(retry int) time.Duration {
+	interval := float64(p.InitialInterval)
+	for i := 1; i < retry; i++ {
+		interval *= p.Multiplier
+		if p.MaxInterval > 0 && interval >= float64(p.MaxInterval) {
+			return p.MaxInterval
+		}
+	}
+
+	return time.Duration(interval)
+}
+
+// Backoff returns the jittered delay before the given retry (counting from 1).
+func (p Policy) Backoff(retry int) time.Duration {
+	return p.jittered(p.Interval(retry))
+}
+
+func (p Policy) jittered(d time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return d
+	}
+
+	delta := p.Jitter * float64(d)
+	return time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+}
+
+// Do calls fn until it succeeds, ctx is done or the next attempt would start after MaxElapsedTime.
+func Do(ctx context.Context, name string, p Policy, fn func(context.Context) error) error {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			logger.FromContext(ctx).Info().
+				Str("dependency", name).
+				Int("attempt", attempt).
+				Dur("elapsed", time.Since(start)).
+				Msg("Dependency is available")
+			return nil
+		}
+
+		delay := p.Backoff(attempt)
+		elapsed := time.Since(start)
+		if p.MaxElapsedTime > 0 && elapsed+delay > p.MaxElapsedTime {
+			logger.FromContext(ctx).Error().
+				Str("dependency", name).
+				Int("attempt", attempt).
+				Dur("elapsed", elapsed).
+				Err(err).
+				Msg("Giving up on dependency")
+			return fmt.Errorf("%s is unavailable after %d attempts in %s: %w", name, attempt, elapsed.Round(time.Millisecond), err)
+		}
+
+		logger.FromContext(ctx).Warn().
+			Str("dependency", name).
+			Int("attempt", attempt).
+			Dur("elapsed", elapsed).
+			Dur("retry_in", delay).
+			Err(err).
+			Msg("Dependency is unavailable, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPolicy_Interval(t *testing.T) {
+	p := Policy{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      2,
+	}
+
+	assert.Equal(t, 100*time.Millisecond, p.Interval(1))
+	assert.Equal(t, 200*time.Millisecond, p.Interval(2))
+	assert.Equal(t, 800*time.Millisecond, p.Interval(4))
+	assert.Equal(t, time.Second, p.Interval(5))
+	assert.Equal(t, time.Second, p.Interval(50))
+}
+
+func TestDo_SucceedsAfterRetries(t *testing.T) {
+	p := Policy{InitialInterval: time.Millisecond, Multiplier: 1, MaxElapsedTime: time.Second}
+
+	calls := 0
+	err := Do(context.Background(), "test", p, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDo_GivesUpAfterMaxElapsedTime(t *testing.T) {
+	p := Policy{InitialInterval: 10 * time.Millisecond, Multiplier: 1, MaxElapsedTime: 35 * time.Millisecond}
+	errDown := errors.New("down")
+
+	calls := 0
+	err := Do(context.Background(), "test", p, func(context.Context) error {
+		calls++
+		return errDown
+	})
+
+	assert.ErrorIs(t, err, errDown)
+	assert.Greater(t, calls, 1)
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned for a delivery to an address inside the service's own networks.
+var ErrForbiddenAddress = errors.New("webhook address is not publicly routable")
+
+// reservedNetworks are not reachable on the public internet, on top of the loopback, private,
+// link-local, multicast and unspecified addresses recognised by netip.
+var reservedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// guardDial returns a net.Dialer Control function that refuses connections to addresses that
+// are not publicly routable, unless they are in allowed. Control runs after the host name is
+// resolved, so a name that resolves to an internal address is refused as well.
+func guardDial(allowed []netip.Prefix) func(network, address string, _ syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		addr, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+		}
+
+		ip := addr.Addr().Unmap()
+		for _, prefix := range allowed {
+			if prefix.Contains(ip) {
+				return nil
+			}
+		}
+		if !publicAddr(ip) {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+		}
+		return nil
+	}
+}
+
+func publicAddr(ip netip.Addr) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedNetworks {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+// Package webhook delivers transaction events to the URLs integrators registered.
+package webhook
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/logger"
+	"TransactiStream/internal/metrics"
+	"TransactiStream/internal/retry"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	DeliveryHeader  = "X-Webhook-ID"
+	EventHeader     = "X-Webhook-Event"
+)
+
+// leaseMargin is added to the request timeout to get the time a claimed delivery is hidden from
+// other workers, leaving room to record the outcome of the attempt.
+const leaseMargin = 30 * time.Second
+
+// maxResponseBytes of a response body are read so the connection can be reused.
+const maxResponseBytes = 64 << 10
+
+// Store keeps deliveries between attempts.
+type Store interface {
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*domain.WebhookDelivery, error)
+	RecordDeliveryAttempt(ctx context.Context, d *domain.WebhookDelivery) error
+}
+
+type Options struct {
+	PollInterval time.Duration
+	// BatchSize is the number of deliveries claimed and attempted concurrently per poll.
+	BatchSize int
+	// Timeout bounds a single attempt, from connecting to reading the response.
+	Timeout time.Duration
+	// MaxAttempts and Retry.MaxElapsedTime, counted from the creation of a delivery,
+	// bound how long a failing delivery is retried before it is marked failed.
+	MaxAttempts int
+	Retry       retry.Policy
+	// AllowedNetworks may be delivered to even though they are not publicly routable,
+	// e.g. the network of an integrator reached over a VPN.
+	AllowedNetworks []netip.Prefix
+}
+
+// Worker attempts due deliveries and schedules retries of failed attempts.
+type Worker struct {
+	store  Store
+	client *http.Client
+	opts   Options
+}
+
+func NewWorker(store Store, opts Options) *Worker {
+	return &Worker{
+		store: store,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: newTransport(opts.AllowedNetworks),
+			// a redirect is reported as a failed attempt rather than followed with the signed payload
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		opts: opts,
+	}
+}
+
+// newTransport connects only to publicly routable addresses and those in allowed. Proxies are
+// not used, since the proxy rather than the webhook host would be checked.
+func newTransport(allowed []netip.Prefix) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   guardDial(allowed),
+	}).DialContext
+	return transport
+}
+
+// Run delivers due webhooks every PollInterval until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := w.DeliverDue(ctx)
+		if err != nil {
+			logger.FromContext(ctx).Error().Err(err).Msg("failed to claim webhook deliveries")
+		}
+
+		// a full batch means more deliveries may already be due
+		if err == nil && n == w.opts.BatchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue claims up to BatchSize due deliveries, attempts them concurrently and returns how many there were.
+func (w *Worker) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := w.store.ClaimDeliveries(ctx, w.opts.BatchSize, w.opts.Timeout+leaseMargin)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.deliver(ctx, d)
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// deliver makes one attempt of d and stores its outcome.
+func (w *Worker) deliver(ctx context.Context, d *domain.WebhookDelivery) {
+	ctx = logger.With(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("webhook_id", d.WebhookID).Str("delivery_id", d.ID)
+	})
+
+	now := time.Now()
+	code, err := w.send(ctx, d, now)
+	if ctx.Err() != nil {
+		// shutting down: the lease expires and another worker retries without counting this attempt
+		return
+	}
+
+	d.Attempts++
+	d.LastAttemptAt = &now
+	d.ResponseStatus = code
+	d.LastError = ""
+	d.NextAttemptAt = nil
+
+	result := "succeeded"
+	switch {
+	case err == nil:
+		d.Status = domain.DeliverySucceeded
+		d.DeliveredAt = &now
+	case w.exhausted(d, now):
+		d.Status = domain.DeliveryFailed
+		d.LastError = err.Error()
+		result = "failed"
+	default:
+		next := now.Add(w.opts.Retry.Backoff(d.Attempts))
+		d.NextAttemptAt = &next
+		d.LastError = err.Error()
+		result = "retry"
+	}
+	metrics.WebhookDeliveries.WithLabelValues(result).Inc()
+
+	log := logger.FromContext(ctx)
+	switch result {
+	case "succeeded":
+		log.Info().Int("attempt", d.Attempts).Int("status", code).Msg("Webhook delivered")
+	case "failed":
+		log.Error().Int("attempt", d.Attempts).Err(err).Msg("Giving up on webhook delivery")
+	default:
+		log.Warn().Int("attempt", d.Attempts).Time("next_attempt_at", *d.NextAttemptAt).Err(err).Msg("Webhook delivery failed, retrying")
+	}
+
+	if err = w.store.RecordDeliveryAttempt(context.WithoutCancel(ctx), d); err != nil {
+		log.Error().Err(err).Msg("failed to record webhook delivery attempt")
+	}
+}
+
+// exhausted reports whether d must not be retried after its last attempt at now.
+func (w *Worker) exhausted(d *domain.WebhookDelivery, now time.Time) bool {
+	if w.opts.MaxAttempts > 0 && d.Attempts >= w.opts.MaxAttempts {
+		return true
+	}
+	maxElapsed := w.opts.Retry.MaxElapsedTime
+	return maxElapsed > 0 && now.Add(w.opts.Retry.Interval(d.Attempts)).Sub(d.CreatedAt) > maxElapsed
+}
+
+// send POSTs the signed payload of d and returns the response status, if any.
+// Anything but a 2xx response is an error.
+func (w *Worker) send(ctx context.Context, d *domain.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("invalid webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TransactiStream-Webhook/1.0")
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(SignatureHeader, Sign(d.Secret, now, d.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,225 @@
+package webhook
+
+import (
+	"TransactiStream/internal/domain"
+	"TransactiStream/internal/retry"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	mu       sync.Mutex
+	due      []*domain.WebhookDelivery
+	recorded []*domain.WebhookDelivery
+}
+
+func (f *fakeStore) ClaimDeliveries(_ context.Context, limit int, _ time.Duration) ([]*domain.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := min(limit, len(f.due))
+	claimed := f.due[:n]
+	f.due = f.due[n:]
+	return claimed, nil
+}
+
+func (f *fakeStore) RecordDeliveryAttempt(_ context.Context, d *domain.WebhookDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	copied := *d
+	f.recorded = append(f.recorded, &copied)
+	return nil
+}
+
+func newDelivery(url string) *domain.WebhookDelivery {
+	return &domain.WebhookDelivery{
+		ID:        "d1",
+		WebhookID: "w1",
+		EventType: domain.EventTransactionSucceeded,
+		Payload:   []byte(`{"type":"transaction.succeeded"}`),
+		Status:    domain.DeliveryPending,
+		CreatedAt: time.Now(),
+		URL:       url,
+		Secret:    "0123456789abcdef",
+	}
+}
+
+func newTestWorker(store Store) *Worker {
+	return NewWorker(store, Options{
+		PollInterval: 10 * time.Millisecond,
+		BatchSize:    10,
+		Timeout:      time.Second,
+		MaxAttempts:  3,
+		Retry: retry.Policy{
+			InitialInterval: time.Minute,
+			MaxInterval:     time.Hour,
+			Multiplier:      2,
+			MaxElapsedTime:  24 * time.Hour,
+		},
+		// test servers listen on loopback
+		AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+	})
+}
+
+func TestWorker_DeliversSigned(t *testing.T) {
+	var (
+		header http.Header
+		body   []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{due: []*domain.WebhookDelivery{newDelivery(srv.URL)}}
+	n, err := newTestWorker(store).DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.Equal(t, `{"type":"transaction.succeeded"}`, string(body))
+	assert.Equal(t, "d1", header.Get(DeliveryHeader))
+	assert.Equal(t, domain.EventTransactionSucceeded, header.Get(EventHeader))
+	assert.NoError(t, Verify("0123456789abcdef", header.Get(SignatureHeader), body, time.Minute, time.Now()))
+
+	require.Len(t, store.recorded, 1)
+	d := store.recorded[0]
+	assert.Equal(t, domain.DeliverySucceeded, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, http.StatusNoContent, d.ResponseStatus)
+	assert.NotNil(t, d.DeliveredAt)
+	assert.Nil(t, d.NextAttemptAt)
+}
+
+func TestWorker_RetriesThenFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{}
+	worker := newTestWorker(store)
+	d := newDelivery(srv.URL)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		store.due = []*domain.WebhookDelivery{d}
+		_, err := worker.DeliverDue(context.Background())
+		require.NoError(t, err)
+
+		got := store.recorded[len(store.recorded)-1]
+		assert.Equal(t, attempt, got.Attempts)
+		assert.Equal(t, http.StatusServiceUnavailable, got.ResponseStatus)
+		assert.Equal(t, "unexpected response status 503", got.LastError)
+		if attempt < 3 {
+			assert.Equal(t, domain.DeliveryPending, got.Status)
+			require.NotNil(t, got.NextAttemptAt)
+			assert.WithinDuration(t, time.Now().Add(worker.opts.Retry.Interval(attempt)), *got.NextAttemptAt, time.Second)
+		} else {
+			assert.Equal(t, domain.DeliveryFailed, got.Status)
+			assert.Nil(t, got.NextAttemptAt)
+		}
+	}
+}
+
+func TestWorker_DoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			followed = true
+			return
+		}
+		http.Redirect(w, r, "/moved", http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{due: []*domain.WebhookDelivery{newDelivery(srv.URL)}}
+	_, err := newTestWorker(store).DeliverDue(context.Background())
+	require.NoError(t, err)
+
+	assert.False(t, followed)
+	assert.Equal(t, domain.DeliveryPending, store.recorded[0].Status)
+	assert.Equal(t, http.StatusTemporaryRedirect, store.recorded[0].ResponseStatus)
+}
+
+func TestWorker_RefusesInternalAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{}
+	worker := newTestWorker(store)
+	worker.client.Transport = newTransport(nil)
+
+	for _, url := range []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)} {
+		store.due = []*domain.WebhookDelivery{newDelivery(url)}
+		_, err := worker.DeliverDue(context.Background())
+		require.NoError(t, err)
+
+		got := store.recorded[len(store.recorded)-1]
+		assert.Equal(t, domain.DeliveryPending, got.Status)
+		assert.Contains(t, got.LastError, ErrForbiddenAddress.Error())
+	}
+	assert.False(t, called)
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"64:ff9b::a00:1":  false,
+	}
+
+	for addr, want := range tests {
+		assert.Equal(t, want, publicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestWorker_GivesUpAfterMaxElapsedTime(t *testing.T) {
+	store := &fakeStore{}
+	worker := newTestWorker(store)
+	d := newDelivery("http://127.0.0.1:1")
+	d.CreatedAt = time.Now().Add(-24 * time.Hour)
+	store.due = []*domain.WebhookDelivery{d}
+
+	_, err := worker.DeliverDue(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, domain.DeliveryFailed, store.recorded[0].Status)
+	assert.Zero(t, store.recorded[0].ResponseStatus)
+	assert.NotEmpty(t, store.recorded[0].LastError)
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{}`)
+	header := Sign("secret", now, body)
+
+	assert.NoError(t, Verify("secret", header, body, time.Minute, now.Add(30*time.Second)))
+	assert.ErrorIs(t, Verify("other", header, body, time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", header, []byte(`{"a":1}`), time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", header, body, time.Minute, now.Add(2*time.Minute)), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", "v1=abc", body, time.Minute, now), ErrInvalidSignature)
+}
@@ -9,6 +9,10 @@ import (
 	"time"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Transaction struct {
@@ -27,6 +31,12 @@ func main() {
 
 	ctx := context.Background()
 
+	shutdown, err := setupTracing(ctx)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdown(context.Background())
+
 	log.Println("mocktstream started")
 
 	go func() {
@@ -56,18 +66,26 @@ func processTransactions(ctx context.Context, brokers []string, readTopic, write
 	}
 	defer writer.Close()
 
+	tracer := otel.Tracer("mocktstream")
+
 	for {
 		m, err := reader.ReadMessage(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to read message: %w", err)
 		}
 
+		msgCtx := otel.GetTextMapPropagator().Extract(ctx, headerCarrier{&m.Headers})
+		msgCtx, span := tracer.Start(msgCtx, readTopic+" process", trace.WithSpanKind(trace.SpanKindConsumer))
+
 		var trans Transaction
 		err = json.Unmarshal(m.Value, &trans)
 		if err != nil {
 			log.Printf("Failed to unmarshal message: %v", err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
 			continue
 		}
+		span.SetAttributes(attribute.String("transaction.id", trans.ID))
 
 		log.Printf("Received transaction: %+v\n", trans)
 
@@ -82,23 +100,33 @@ func processTransactions(ctx context.Context, brokers []string, readTopic, write
 		} else {
 			trans.Done = true
 		}
+		span.SetAttributes(attribute.Bool("transaction.done", trans.Done))
 
 		message, err := json.Marshal(trans)
 		if err != nil {
 			log.Printf("Failed to marshal transaction: %v", err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
 			continue
 		}
 
-		err = writer.WriteMessages(ctx,
-			kafka.Message{
-				Key:   []byte(trans.ID),
-				Value: message,
-			},
-		)
+		out := kafka.Message{
+			Key:   []byte(trans.ID),
+			Value: message,
+		}
+		otel.GetTextMapPropagator().Inject(msgCtx, headerCarrier{&out.Headers})
+		if id := (headerCarrier{&m.Headers}).Get(requestIDHeader); id != "" {
+			headerCarrier{&out.Headers}.Set(requestIDHeader, id)
+		}
+
+		err = writer.WriteMessages(msgCtx, out)
 		if err != nil {
 			log.Printf("Failed to write message: %v", err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
 			continue
 		}
+		span.End()
 
 		log.Printf("Processed transaction: %+v\n", trans)
 	}